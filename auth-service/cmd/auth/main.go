@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/genryusaishigikuni/messenger/auth-service/internal/handlers"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/password"
 	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
 	"github.com/gorilla/mux"
@@ -36,19 +38,51 @@ func main() {
 		panic(err)
 	}
 
+	// Load signing keys. A retired key stays published for as long as the
+	// access tokens it signed can live.
+	utils.Info("Loading signing keys...")
+	ring, err := keys.Load(cfg.KeysDir, cfg.SigningAlgorithm, cfg.AccessTokenTTL)
+	if err != nil {
+		utils.Error("Failed to load signing keys: " + err.Error())
+		panic(err)
+	}
+	go ring.RunRotation(cfg.KeyRotationInterval)
+
+	// Password policy and hashing
+	policy := &password.Policy{
+		MinLength:  cfg.PasswordMinLength,
+		MaxLength:  cfg.PasswordMaxLength,
+		MinClasses: cfg.PasswordMinClasses,
+	}
+	if err := policy.LoadDenyList(cfg.PasswordDenyListPath, cfg.PasswordDenyListRequired); err != nil {
+		utils.Error("Failed to load password deny list: " + err.Error())
+		panic(err)
+	}
+	hasher := password.NewHasher(password.Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	}, cfg.Argon2MaxConcurrency)
+
 	// Prepare router
 	utils.Info("Setting up routes...")
 	r := mux.NewRouter()
 
 	// Handlers
-	r.HandleFunc("/api/auth/register", handlers.RegisterHandler(db)).Methods("POST")
-	r.HandleFunc("/api/auth/login", handlers.LoginHandler(db, cfg.JWTSecret)).Methods("POST")
-	r.HandleFunc("/api/auth/validate", handlers.ValidateHandler(cfg.JWTSecret)).Methods("GET")
+	r.HandleFunc("/api/auth/register", handlers.RegisterHandler(db, policy, hasher)).Methods("POST")
+	r.HandleFunc("/api/auth/login", handlers.LoginHandler(db, cfg, ring, hasher)).Methods("POST")
+	r.HandleFunc("/api/auth/refresh", handlers.RefreshHandler(db, cfg, ring)).Methods("POST")
+	r.HandleFunc("/api/auth/logout", handlers.LogoutHandler(db, cfg, ring)).Methods("POST")
+	r.HandleFunc("/api/auth/validate", handlers.ValidateHandler(db, ring)).Methods("GET")
+	r.HandleFunc("/api/auth/sessions", handlers.GetSessionsHandler(db, ring)).Methods("GET")
+	r.HandleFunc("/api/auth/sessions/{id}", handlers.RevokeSessionHandler(db, cfg, ring)).Methods("DELETE")
+	r.HandleFunc("/api/auth/users", handlers.LookupUsersHandler(db, ring)).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler(ring)).Methods("GET")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 
 		if req.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -3,8 +3,13 @@ module github.com/genryusaishigikuni/messenger/auth-service
 go 1.23.4
 
 require (
-	github.com/golang-jwt/jwt/v4 v4.5.1 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	github.com/genryusaishigikuni/messenger/tokenverify v0.0.0
+	github.com/golang-jwt/jwt/v4 v4.5.1
+	github.com/gorilla/mux v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/crypto v0.31.0
 )
+
+require golang.org/x/sys v0.28.0 // indirect
+
+replace github.com/genryusaishigikuni/messenger/tokenverify => ../tokenverify
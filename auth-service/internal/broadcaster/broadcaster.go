@@ -0,0 +1,84 @@
+package broadcaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
+)
+
+// revocationTarget is a service that verifies access tokens itself and so
+// has to hear about revoked sessions.
+type revocationTarget struct {
+	name       string
+	urlEnv     string
+	defaultURL string
+	path       string
+}
+
+var revocationTargets = []revocationTarget{
+	{"gateway", "GATEWAY_SERVICE_URL", "http://localhost:8080", "/api/sessions/revoked"},
+	{"message service", "MESSAGE_SERVICE_URL", "http://localhost:8081", "/api/internal/sessions/revoked"},
+	{"presence service", "PRESENCE_SERVICE_URL", "http://localhost:8083", "/api/internal/sessions/revoked"},
+}
+
+// BroadcastSessionRevoked tells every service that verifies access tokens
+// that the session was signed out, so they reject its tokens from now on;
+// the Gateway Service also closes the session's WebSockets. accessTokenTTL
+// is how long the services have to remember the session.
+func BroadcastSessionRevoked(sessionID string, accessTokenTTL time.Duration) {
+	utils.Info("Notifying services of revoked session: " + sessionID)
+
+	data, err := json.Marshal(tokenverify.Revocation{
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+	})
+	if err != nil {
+		utils.Error("Failed to marshal session event: " + err.Error())
+		return
+	}
+
+	for _, target := range revocationTargets {
+		baseURL := os.Getenv(target.urlEnv)
+		if baseURL == "" {
+			baseURL = target.defaultURL
+			utils.Info(target.urlEnv + " not set. Using default: " + baseURL)
+		}
+		notifyRevoked(target.name, baseURL+target.path, data)
+	}
+}
+
+func notifyRevoked(name, url string, data []byte) {
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		utils.Error("Failed to create session event request: " + err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	internalauth.Sign(req, os.Getenv("INTERNAL_API_TOKEN"))
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		utils.Error("Failed to send session event to " + name + ": " + err.Error())
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			utils.Error("Failed to close session event response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		utils.Error("The " + name + " returned status " + http.StatusText(resp.StatusCode) + " for revoked session.")
+	} else {
+		utils.Info("The " + name + " was notified of revoked session.")
+	}
+}
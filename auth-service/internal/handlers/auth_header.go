@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/jwt"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// claimsFromRequest validates the bearer token of the request and checks
+// that its session has not been signed out. The error text is suitable for
+// the 401 response.
+func claimsFromRequest(r *http.Request, db *sql.DB, ring *keys.KeyRing) (*models.TokenClaims, error) {
+	// Get Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		utils.Error("Authorization header is missing")
+		return nil, errors.New("No token provided")
+	}
+
+	// Check Authorization header format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		utils.Error("Invalid authorization header format")
+		return nil, errors.New("Invalid authorization header format")
+	}
+
+	// Validate token
+	utils.Info("Validating token...")
+	claims, err := jwt.ValidateToken(ring, parts[1])
+	if err != nil {
+		utils.Error("Invalid token: " + err.Error())
+		return nil, errors.New("Invalid token")
+	}
+
+	session, err := storage.GetSession(db, claims.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		utils.Error("Session is missing or revoked: " + claims.SessionID)
+		return nil, errors.New("Session revoked")
+	}
+	_ = storage.TouchSession(db, session.ID)
+	return claims, nil
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// JWKSHandler GET /.well-known/jwks.json publishes the public keys that
+// tokens are signed with, including retired keys whose tokens are still
+// valid, so that other services can verify tokens themselves.
+func JWKSHandler(ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling JWKS request...")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(ring.JWKS()); err != nil {
+			utils.Error("Failed to encode response: " + err.Error())
+		}
+	}
+}
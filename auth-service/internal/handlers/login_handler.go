@@ -6,9 +6,8 @@ import (
 	"net/http"
 	"strings"
 
-	"golang.org/x/crypto/bcrypt"
-
-	"github.com/genryusaishigikuni/messenger/auth-service/internal/jwt"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/password"
 	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
 )
@@ -18,7 +17,10 @@ type loginRequest struct {
 	Password string `json:"password"`
 }
 
-func LoginHandler(db *sql.DB, jwtSecret string) http.HandlerFunc {
+// LoginHandler POST /api/auth/login starts a session and returns a
+// short-lived access token together with a refresh token. Password hashes
+// made with bcrypt or outdated Argon2 parameters are upgraded on the way.
+func LoginHandler(db *sql.DB, cfg utils.Config, ring *keys.KeyRing, hasher *password.Hasher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Handling login request...")
 
@@ -45,25 +47,28 @@ func LoginHandler(db *sql.DB, jwtSecret string) http.HandlerFunc {
 		}
 
 		utils.Info("Validating password...")
-		if bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(req.Password)) != nil {
+		match, needsRehash, err := hasher.Verify(req.Password, user.HashedPassword)
+		if err != nil {
+			utils.Error("Failed to verify password: " + err.Error())
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if !match {
 			utils.Error("Password validation failed")
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
 
-		utils.Info("Generating JWT token...")
-		token, err := jwt.GenerateToken(jwtSecret, user.ID, user.Username)
-		if err != nil {
-			utils.Error("Failed to generate JWT token: " + err.Error())
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
+		if needsRehash {
+			utils.Info("Upgrading password hash for user: " + user.Username)
+			if hashed, err := hasher.Hash(req.Password); err != nil {
+				utils.Error("Failed to rehash password: " + err.Error())
+			} else if err := storage.UpdatePasswordHash(db, user.ID, hashed); err == nil {
+				user.HashedPassword = hashed
+			}
 		}
 
-		utils.Info("Login successful, responding with token")
-		w.Header().Set("Content-Type", "application/json")
-		_, err = w.Write([]byte(`{"token":"` + token + `"}`))
-		if err != nil {
-			utils.Error("Failed to write response: " + err.Error())
-		}
+		utils.Info("Login successful, starting session")
+		issueTokens(w, r, db, cfg, ring, user)
 	}
 }
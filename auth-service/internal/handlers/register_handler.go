@@ -3,11 +3,11 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
-	"golang.org/x/crypto/bcrypt"
-
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/password"
 	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
 )
@@ -17,7 +17,9 @@ type registerRequest struct {
 	Password string `json:"password"`
 }
 
-func RegisterHandler(db *sql.DB) http.HandlerFunc {
+// RegisterHandler POST /api/auth/register creates an account. The password
+// must satisfy the policy and is stored as an Argon2id hash.
+func RegisterHandler(db *sql.DB, policy *password.Policy, hasher *password.Hasher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Handling user registration request...")
 
@@ -35,6 +37,13 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		var policyErr *password.PolicyError
+		if err := policy.Check(req.Username, req.Password); errors.As(err, &policyErr) {
+			utils.Error("Password rejected by policy: " + policyErr.Reason)
+			http.Error(w, policyErr.Reason, http.StatusBadRequest)
+			return
+		}
+
 		utils.Info("Checking if username already exists...")
 		exists, err := storage.UserExists(db, req.Username)
 		if err != nil {
@@ -49,7 +58,7 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		utils.Info("Hashing the user password...")
-		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		hashed, err := hasher.Hash(req.Password)
 		if err != nil {
 			utils.Error("Failed to hash password: " + err.Error())
 			http.Error(w, "Server error", http.StatusInternalServerError)
@@ -57,7 +66,7 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		utils.Info("Creating user in the database...")
-		err = storage.CreateUser(db, req.Username, string(hashed))
+		err = storage.CreateUser(db, req.Username, hashed)
 		if err != nil {
 			utils.Error("Failed to create user: " + err.Error())
 			http.Error(w, "Could not create user", http.StatusInternalServerError)
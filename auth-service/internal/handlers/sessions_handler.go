@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// maxUserAgentLength bounds the user agent kept with a session.
+const maxUserAgentLength = 256
+
+// GetSessionsHandler GET /api/auth/sessions
+// Lists the caller's active sessions, marking the one the request came from.
+func GetSessionsHandler(db *sql.DB, ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling session listing request...")
+
+		claims, err := claimsFromRequest(r, db, ring)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		sessions, err := storage.GetActiveSessions(db, claims.UserID)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == claims.SessionID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"sessions": sessions}); err != nil {
+			utils.Error("Failed to encode response: " + err.Error())
+		}
+	}
+}
+
+// RevokeSessionHandler DELETE /api/auth/sessions/{id}
+// Signs out one of the caller's sessions, possibly the current one. Its
+// tokens stop validating and the gateway closes its WebSockets.
+func RevokeSessionHandler(db *sql.DB, cfg utils.Config, ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling session revocation request...")
+
+		claims, err := claimsFromRequest(r, db, ring)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		// Other users' sessions are reported as missing rather than forbidden
+		session, err := storage.GetSession(db, mux.Vars(r)["id"])
+		if errors.Is(err, storage.ErrSessionNotFound) || (err == nil && (session.UserID != claims.UserID || session.RevokedAt != nil)) {
+			utils.Error("Session not found for user")
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := storage.RevokeSession(db, session.ID); err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		go broadcaster.BroadcastSessionRevoked(session.ID, cfg.AccessTokenTTL)
+
+		utils.Info("Session revoked: " + session.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// clientIP returns the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
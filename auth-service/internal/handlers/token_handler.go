@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/jwt"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// tokenResponse is returned by login and refresh. ExpiresIn is the access
+// token's lifetime in seconds.
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler POST /api/auth/refresh { "refresh_token": "..." }
+// Exchanges a refresh token for a new access and refresh token pair. Each
+// refresh token works once; replaying an old one signs the session out.
+func RefreshHandler(db *sql.DB, cfg utils.Config, ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling token refresh request...")
+
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Invalid request body: " + err.Error())
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+		if req.RefreshToken == "" {
+			utils.Error("Refresh token is empty")
+			http.Error(w, "Refresh token required", http.StatusBadRequest)
+			return
+		}
+
+		refreshToken, err := jwt.GenerateRefreshToken()
+		if err != nil {
+			utils.Error("Failed to generate refresh token: " + err.Error())
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		user, sessionID, err := storage.RotateRefreshToken(db, jwt.HashRefreshToken(req.RefreshToken),
+			jwt.HashRefreshToken(refreshToken), time.Now().Add(cfg.RefreshTokenTTL))
+		if errors.Is(err, storage.ErrRefreshTokenReused) {
+			// The session is gone, so close the sockets still open on it too
+			go broadcaster.BroadcastSessionRevoked(sessionID, cfg.AccessTokenTTL)
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		} else if errors.Is(err, storage.ErrRefreshTokenInvalid) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeTokens(w, cfg, ring, user, sessionID, refreshToken)
+	}
+}
+
+// LogoutHandler POST /api/auth/logout
+// Revokes the session of the bearer token: its refresh token and access
+// tokens stop working and its WebSockets are closed.
+func LogoutHandler(db *sql.DB, cfg utils.Config, ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling logout request...")
+
+		claims, err := claimsFromRequest(r, db, ring)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if err := storage.RevokeSession(db, claims.SessionID); err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		go broadcaster.BroadcastSessionRevoked(claims.SessionID, cfg.AccessTokenTTL)
+
+		utils.Info("Session signed out: " + claims.SessionID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// issueTokens starts a new session for the user and responds with its
+// first access and refresh tokens.
+func issueTokens(w http.ResponseWriter, r *http.Request, db *sql.DB, cfg utils.Config, ring *keys.KeyRing, user *models.User) {
+	sessionID, err := jwt.NewSessionID()
+	if err != nil {
+		utils.Error("Failed to generate session ID: " + err.Error())
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	refreshToken, err := jwt.GenerateRefreshToken()
+	if err != nil {
+		utils.Error("Failed to generate refresh token: " + err.Error())
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IP:        clientIP(r),
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL),
+	}
+	if err := storage.StartSession(db, session, jwt.HashRefreshToken(refreshToken)); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, cfg, ring, user, sessionID, refreshToken)
+}
+
+// writeTokens signs an access token for the session and responds with it
+// and the refresh token.
+func writeTokens(w http.ResponseWriter, cfg utils.Config, ring *keys.KeyRing, user *models.User, sessionID, refreshToken string) {
+	utils.Info("Generating JWT token...")
+	token, err := jwt.GenerateToken(ring, user.ID, user.Username, sessionID, cfg.AccessTokenTTL)
+	if err != nil {
+		utils.Error("Failed to generate JWT token: " + err.Error())
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(cfg.AccessTokenTTL / time.Second),
+	}); err != nil {
+		utils.Error("Failed to write response: " + err.Error())
+	}
+}
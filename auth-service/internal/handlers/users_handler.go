@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// maxLookupUsers bounds how many users one lookup may ask for.
+const maxLookupUsers = 50
+
+type userSummary struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// LookupUsersHandler GET /api/auth/users?username=<name>&id=<id>...
+// resolves usernames to user IDs, and checks that user IDs exist, for any
+// authenticated caller. Unknown users are left out of the response.
+func LookupUsersHandler(db *sql.DB, ring *keys.KeyRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling user lookup request...")
+
+		if _, err := claimsFromRequest(r, db, ring); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		usernames := r.URL.Query()["username"]
+		var ids []int
+		for _, raw := range r.URL.Query()["id"] {
+			id, err := strconv.Atoi(raw)
+			if err != nil || id < 1 {
+				utils.Error("Invalid user ID to look up: " + raw)
+				http.Error(w, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, id)
+		}
+		requested := len(usernames) + len(ids)
+		if requested == 0 {
+			utils.Error("No users to look up")
+			http.Error(w, "At least one username or id required", http.StatusBadRequest)
+			return
+		}
+		if requested > maxLookupUsers {
+			utils.Error("Too many users to look up: " + strconv.Itoa(requested))
+			http.Error(w, "At most "+strconv.Itoa(maxLookupUsers)+" users per lookup", http.StatusBadRequest)
+			return
+		}
+
+		byName, err := storage.GetUsersByUsernames(db, usernames)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		byID, err := storage.GetUsersByIDs(db, ids)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := []userSummary{}
+		seen := make(map[int]bool)
+		for _, u := range append(byName, byID...) {
+			if !seen[u.ID] {
+				seen[u.ID] = true
+				resp = append(resp, userSummary{ID: u.ID, Username: u.Username})
+			}
+		}
+
+		utils.Info("Resolved " + strconv.Itoa(len(resp)) + " of " + strconv.Itoa(requested) + " users")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"users": resp}); err != nil {
+			utils.Error("Failed to encode response: " + err.Error())
+		}
+	}
+}
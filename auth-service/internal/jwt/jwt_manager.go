@@ -1,28 +1,37 @@
 package jwt
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
 	"strconv"
 	"time"
 
+	"github.com/genryusaishigikuni/messenger/auth-service/internal/keys"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func GenerateToken(secret string, userID int, username string) (string, error) {
+// GenerateToken issues an access token for the user's session that expires
+// after ttl, signed with the ring's active key and naming it in the kid header.
+func GenerateToken(ring *keys.KeyRing, userID int, username, sessionID string, ttl time.Duration) (string, error) {
 	utils.Info("Generating token...")
 
 	claims := &models.TokenClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secret))
+	key := ring.SigningKey()
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	signedToken, err := token.SignedString(key.Private)
 	if err != nil {
 		utils.Error("Failed to sign token: " + err.Error())
 		return "", err
@@ -32,18 +41,39 @@ func GenerateToken(secret string, userID int, username string) (string, error) {
 	return signedToken, nil
 }
 
-func ValidateToken(secret, tokenString string) (*models.TokenClaims, error) {
+// ValidateToken checks the token against the published key named by its kid
+// header. The token must use that key's algorithm and carry a session ID.
+func ValidateToken(ring *keys.KeyRing, tokenString string) (*models.TokenClaims, error) {
 	utils.Info("Validating token...")
 
 	token, err := jwt.ParseWithClaims(tokenString, &models.TokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ring.VerificationKey(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key: " + kid)
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing method: " + t.Method.Alg())
+		}
+		switch private := key.Private.(type) {
+		case ed25519.PrivateKey:
+			return private.Public(), nil
+		case *rsa.PrivateKey:
+			return &private.PublicKey, nil
+		}
+		return nil, errors.New("unsupported signing key: " + kid)
+	}, jwt.WithValidMethods([]string{keys.AlgEdDSA, keys.AlgRS256}))
 	if err != nil {
 		utils.Error("Failed to parse token: " + err.Error())
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*models.TokenClaims); ok && token.Valid {
+		// Every token is bound to a session so that signing out revokes it
+		if claims.SessionID == "" {
+			utils.Error("Token has no session")
+			return nil, errors.New("token has no session")
+		}
 		utils.Info("Token validated successfully for user ID: " + strconv.Itoa(claims.UserID))
 		return claims, nil
 	}
@@ -51,3 +81,10 @@ func ValidateToken(secret, tokenString string) (*models.TokenClaims, error) {
 	utils.Error("Invalid token signature")
 	return nil, jwt.ErrSignatureInvalid
 }
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == keys.AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
@@ -0,0 +1,35 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRefreshToken returns a new opaque refresh token. Only its hash is
+// ever stored.
+func GenerateRefreshToken() (string, error) {
+	return randomString(32)
+}
+
+// HashRefreshToken returns the form of a refresh token kept in storage.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSessionID returns a random identifier for a login session. It is the
+// family ID shared by every refresh token rotated from that login and the
+// sid claim of its access tokens.
+func NewSessionID() (string, error) {
+	return randomString(16)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,275 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// Signing algorithms, as they appear in the JWT alg header.
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+// kidTimeLayout formats the creation time that starts every generated key ID.
+const kidTimeLayout = "20060102T150405Z"
+
+// Key is a signing key pair. A retired key no longer signs tokens but stays
+// published until every token it signed has expired.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	RetiredAt time.Time // zero for the active key
+}
+
+// KeyRing holds the active signing key and the retired keys whose tokens
+// may still be in use. Keys are kept on disk as PKCS#8 PEM files named
+// <kid>.pem, so they survive restarts and can be provisioned by operators.
+type KeyRing struct {
+	mu        sync.RWMutex
+	dir       string
+	algorithm string        // algorithm of newly generated keys
+	tokenTTL  time.Duration // how long a retired key stays published
+	keys      []*Key        // oldest first; the last one is active
+}
+
+// Load reads every key in dir, generating a first key with the given
+// algorithm when there is none. The newest key becomes the active one and
+// each older key counts as retired when its successor was created.
+func Load(dir, algorithm string, tokenTTL time.Duration) (*KeyRing, error) {
+	if algorithm != AlgEdDSA && algorithm != AlgRS256 {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	utils.Info("Loading signing keys from " + dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ring := &KeyRing{dir: dir, algorithm: algorithm, tokenTTL: tokenTTL}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		key, err := loadKeyFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %s: %w", entry.Name(), err)
+		}
+		ring.keys = append(ring.keys, key)
+	}
+
+	sort.Slice(ring.keys, func(i, j int) bool {
+		if ring.keys[i].CreatedAt.Equal(ring.keys[j].CreatedAt) {
+			return ring.keys[i].ID < ring.keys[j].ID
+		}
+		return ring.keys[i].CreatedAt.Before(ring.keys[j].CreatedAt)
+	})
+	for i := 0; i < len(ring.keys)-1; i++ {
+		ring.keys[i].RetiredAt = ring.keys[i+1].CreatedAt
+	}
+	ring.prune()
+
+	if len(ring.keys) == 0 {
+		utils.Info("No signing keys found, generating one")
+		if err := ring.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	utils.Info(fmt.Sprintf("Loaded %d signing keys, active key: %s", len(ring.keys), ring.SigningKey().ID))
+	return ring, nil
+}
+
+func loadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("expected a PKCS#8 PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	key.CreatedAt, err = keyCreatedAt(path, key.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = AlgEdDSA, private
+	case *rsa.PrivateKey:
+		key.Algorithm, key.Private = AlgRS256, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// keyCreatedAt reads the creation time from the timestamp that starts a
+// generated key ID, so that copying or touching the file cannot reorder the
+// ring. Keys provisioned under other names fall back to the file's mtime.
+func keyCreatedAt(path, kid string) (time.Time, error) {
+	if len(kid) > len(kidTimeLayout) && kid[len(kidTimeLayout)] == '-' {
+		if created, err := time.Parse(kidTimeLayout, kid[:len(kidTimeLayout)]); err == nil {
+			return created, nil
+		}
+	}
+	utils.Info("Key " + kid + " has no timestamp in its ID, using the file modification time")
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime().UTC(), nil
+}
+
+// SigningKey returns the key that signs new tokens.
+func (r *KeyRing) SigningKey() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[len(r.keys)-1]
+}
+
+// VerificationKey returns the published key with the given ID.
+func (r *KeyRing) VerificationKey(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// PublishedKeys returns every key that verifiers should accept, oldest first.
+func (r *KeyRing) PublishedKeys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Key(nil), r.keys...)
+}
+
+// Rotate generates a new active key, writes it to disk and retires the
+// previous one. Keys retired long enough ago are dropped at the same time.
+func (r *KeyRing) Rotate() error {
+	key, err := generateKey(r.algorithm)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyFile(filepath.Join(r.dir, key.ID+".pem"), key.Private); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.keys) > 0 {
+		r.keys[len(r.keys)-1].RetiredAt = key.CreatedAt
+	}
+	r.keys = append(r.keys, key)
+	r.prune()
+	utils.Info("Rotated signing key, active key: " + key.ID)
+	return nil
+}
+
+// RunRotation rotates the active key whenever it is older than interval, or
+// signs with a different algorithm than configured. It never returns.
+func (r *KeyRing) RunRotation(interval time.Duration) {
+	ticker := time.NewTicker(min(interval, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		active := r.SigningKey()
+		if time.Since(active.CreatedAt) >= interval || active.Algorithm != r.algorithm {
+			if err := r.Rotate(); err != nil {
+				utils.Error("Failed to rotate signing key: " + err.Error())
+			}
+		} else {
+			r.mu.Lock()
+			r.prune()
+			r.mu.Unlock()
+		}
+		<-ticker.C
+	}
+}
+
+// prune drops retired keys whose tokens have all expired, deleting their
+// files. Must be called with r.mu held or before the ring is shared.
+func (r *KeyRing) prune() {
+	cutoff := time.Now().Add(-r.tokenTTL)
+	kept := r.keys[:0]
+	for _, key := range r.keys {
+		if !key.RetiredAt.IsZero() && key.RetiredAt.Before(cutoff) {
+			utils.Info("Removing expired signing key: " + key.ID)
+			if err := os.Remove(filepath.Join(r.dir, key.ID+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				utils.Error("Failed to remove expired key file: " + err.Error())
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	r.keys = kept
+}
+
+func generateKey(algorithm string) (*Key, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	// Whole seconds, so the time matches what Load later reads back from the ID
+	now := time.Now().UTC().Truncate(time.Second)
+	key := &Key{
+		ID:        now.Format(kidTimeLayout) + "-" + hex.EncodeToString(suffix),
+		Algorithm: algorithm,
+		CreatedAt: now,
+	}
+
+	switch algorithm {
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = private
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = private
+	}
+	return key, nil
+}
+
+func writeKeyFile(path string, private crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+}
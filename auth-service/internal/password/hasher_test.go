@@ -0,0 +1,109 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keep hashing fast; production uses far more memory.
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestHashRoundTrip(t *testing.T) {
+	h := NewHasher(testParams, 2)
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("Hash() = %q, want a PHC string with the hasher's parameters", encoded)
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Memory != 64 || params.Iterations != 1 || params.Parallelism != 1 || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("decoded params = %+v with %d-byte salt and %d-byte key", params, len(salt), len(key))
+	}
+
+	match, rehash, err := h.Verify("correct horse", encoded)
+	if err != nil || !match || rehash {
+		t.Errorf("Verify(right password) = %v, %v, %v; want match without rehash", match, rehash, err)
+	}
+	match, _, err = h.Verify("wrong horse", encoded)
+	if err != nil || match {
+		t.Errorf("Verify(wrong password) = %v, %v; want no match", match, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("legacy"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker, err := NewHasher(Params{Memory: 32, Iterations: 1, Parallelism: 1}, 1).Hash("old params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHasher(testParams, 1)
+
+	tests := []struct {
+		name       string
+		password   string
+		encoded    string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "bcrypt", password: "legacy", encoded: string(bcryptHash), wantMatch: true, wantRehash: true},
+		{name: "bcrypt mismatch", password: "other", encoded: string(bcryptHash)},
+		{name: "changed params", password: "old params", encoded: weaker, wantMatch: true, wantRehash: true},
+		{name: "changed params mismatch", password: "other", encoded: weaker},
+		{name: "unknown format", password: "x", encoded: "$md5$abc", wantErr: ErrUnknownHashFormat},
+		{name: "wrong argon2 version", password: "x", encoded: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", wantErr: ErrUnknownHashFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := h.Verify(tt.password, tt.encoded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("Verify() = %v, %v; want %v, %v", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestHasherLimitsConcurrency(t *testing.T) {
+	h := NewHasher(testParams, 1)
+	h.acquire() // the only slot is busy
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := h.Hash("waits"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Hash() ran while every slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	h.release()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Hash() did not run after a slot was freed")
+	}
+
+	if got := cap(NewHasher(testParams, 0).slots); got != 1 {
+		t.Errorf("NewHasher(maxConcurrent 0) allows %d hashes at once, want 1", got)
+	}
+}
@@ -0,0 +1,115 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// PolicyError explains why a password was rejected. Its message is meant
+// for the user.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// Policy decides which passwords are acceptable for new accounts.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MinClasses is how many of lowercase, uppercase, digits and symbols a
+	// password must mix.
+	MinClasses int
+
+	denied map[string]struct{} // lowercased
+}
+
+// LoadDenyList reads common passwords from path, one per line. Blank lines
+// and lines starting with # are ignored. A missing file is an error when
+// required is set; otherwise it leaves the deny list empty.
+func (p *Policy) LoadDenyList(path string, required bool) error {
+	p.denied = map[string]struct{}{}
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		utils.Error("Password deny list not found, continuing without it: " + path)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			utils.Error("Failed to close password deny list: " + err.Error())
+		}
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.denied[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	utils.Info(fmt.Sprintf("Loaded %d denied passwords from %s", len(p.denied), path))
+	return nil
+}
+
+// Check returns a *PolicyError when password may not be used by username.
+func (p *Policy) Check(username, password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at least %d characters long", p.MinLength)}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at most %d characters long", p.MaxLength)}
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return &PolicyError{Reason: fmt.Sprintf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)}
+	}
+
+	lowered := strings.ToLower(password)
+	if _, ok := p.denied[lowered]; ok {
+		return &PolicyError{Reason: "Password is too common"}
+	}
+	if strings.Contains(lowered, strings.ToLower(username)) {
+		return &PolicyError{Reason: "Password must not contain the username"}
+	}
+	return nil
+}
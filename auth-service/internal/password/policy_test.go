@@ -0,0 +1,62 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	denyList := filepath.Join(t.TempDir(), "common-passwords.txt")
+	if err := os.WriteFile(denyList, []byte("# common passwords\n\nPassword123!\n  letmein-Now1  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := Policy{MinLength: 10, MaxLength: 20, MinClasses: 3}
+	if err := policy.LoadDenyList(denyList, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string // empty when the password is acceptable
+	}{
+		{name: "acceptable", password: "Tr0ub4dor&3x"},
+		{name: "too short", password: "Ab1!xyz", wantErr: "Password must be at least 10 characters long"},
+		{name: "length counts characters, not bytes", password: "Ünïcödé1!x"},
+		{name: "too long", password: "Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!", wantErr: "Password must be at most 20 characters long"},
+		{name: "too few classes", password: "alllowercase1", wantErr: "Password must mix at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+		{name: "denied", password: "Password123!", wantErr: "Password is too common"},
+		{name: "denied in other case", password: "pASSWORD123!", wantErr: "Password is too common"},
+		{name: "denied after trimming the list", password: "LETMEIN-now1", wantErr: "Password is too common"},
+		{name: "contains username", password: "xxALICE-2024", wantErr: "Password must not contain the username"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check("alice", tt.password)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) || policyErr.Reason != tt.wantErr {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadDenyListMissingFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	var policy Policy
+	if err := policy.LoadDenyList(missing, false); err != nil {
+		t.Errorf("LoadDenyList(optional) error = %v, want none", err)
+	}
+	if err := policy.LoadDenyList(missing, true); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadDenyList(required) error = %v, want os.ErrNotExist", err)
+	}
+}
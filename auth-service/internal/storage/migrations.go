@@ -9,6 +9,10 @@ import (
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
 )
 
+// RunMigrations applies every .sql file in migrationsDir that is not yet
+// recorded in schema_migrations, in file name order. Each file runs in its
+// own transaction together with its record, so a failed migration is retried
+// in full on the next start and a successful one never runs again.
 func RunMigrations(db *sql.DB, migrationsDir string) error {
 	utils.Info("Starting database migrations...")
 
@@ -18,8 +22,28 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 		return err
 	}
 
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		utils.Error("Failed to create schema_migrations table: " + err.Error())
+		return err
+	}
+
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			var applied bool
+			err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE name = ?)", entry.Name()).Scan(&applied)
+			if err != nil {
+				utils.Error(fmt.Sprintf("Failed to check migration %s: %v", entry.Name(), err))
+				return err
+			}
+			if applied {
+				utils.Info("Skipping already applied migration: " + entry.Name())
+				continue
+			}
+
 			path := migrationsDir + "/" + entry.Name()
 			utils.Info("Running migration: " + entry.Name())
 
@@ -29,11 +53,21 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 				return err
 			}
 
-			_, err = db.Exec(string(content))
+			tx, err := db.Begin()
 			if err != nil {
+				return err
+			}
+			if _, err = tx.Exec(string(content)); err == nil {
+				_, err = tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", entry.Name())
+			}
+			if err != nil {
+				_ = tx.Rollback()
 				utils.Error(fmt.Sprintf("Failed to run migration %s: %v", entry.Name(), err))
 				return fmt.Errorf("failed to run migration %s: %v", entry.Name(), err)
 			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
 
 			utils.Info("Successfully ran migration: " + entry.Name())
 		}
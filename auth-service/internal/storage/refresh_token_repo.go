@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// rotated is presented again. Its whole family has been revoked by then.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RotateRefreshToken exchanges the refresh token with oldHash for one with
+// newHash in the same family and returns the token's user and session.
+// Presenting a token that was already rotated revokes the whole session,
+// since either the client or an attacker is holding a stolen copy; the
+// revoked session is still returned alongside ErrRefreshTokenReused.
+func RotateRefreshToken(db *sql.DB, oldHash, newHash string, expiresAt time.Time) (*models.User, string, error) {
+	utils.Info("Rotating refresh token...")
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var id int
+	var familyID string
+	var tokenExpiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	user := &models.User{}
+	err = tx.QueryRow(`SELECT t.id, t.family_id, t.expires_at, t.used_at, t.revoked_at, u.id, u.username
+		FROM refresh_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ?`, oldHash).
+		Scan(&id, &familyID, &tokenExpiresAt, &usedAt, &revokedAt, &user.ID, &user.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.Error("Refresh token not found")
+		return nil, "", ErrRefreshTokenInvalid
+	} else if err != nil {
+		utils.Error("Failed to load refresh token: " + err.Error())
+		return nil, "", err
+	}
+
+	if revokedAt.Valid {
+		utils.Error("Refresh token was revoked: family " + familyID)
+		return nil, "", ErrRefreshTokenInvalid
+	}
+	if usedAt.Valid {
+		utils.Error("Refresh token reuse detected, revoking session " + familyID)
+		if err := revokeSession(tx, familyID); err != nil {
+			return nil, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, "", err
+		}
+		return nil, familyID, ErrRefreshTokenReused
+	}
+	if time.Now().After(tokenExpiresAt) {
+		utils.Error("Refresh token expired: family " + familyID)
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	// A concurrent rotation of the same token loses here rather than both succeeding
+	res, err := tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		utils.Error("Failed to mark refresh token used: " + err.Error())
+		return nil, "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, "", err
+	} else if n == 0 {
+		utils.Error("Refresh token was rotated concurrently: family " + familyID)
+		return nil, "", ErrRefreshTokenInvalid
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		user.ID, familyID, newHash, expiresAt.UTC()); err != nil {
+		utils.Error("Failed to store rotated refresh token: " + err.Error())
+		return nil, "", err
+	}
+	if _, err := tx.Exec("UPDATE sessions SET last_used_at = ?, expires_at = ? WHERE id = ?", time.Now().UTC(), expiresAt.UTC(), familyID); err != nil {
+		utils.Error("Failed to update session: " + err.Error())
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	utils.Info("Refresh token rotated for family: " + familyID)
+	return user, familyID, nil
+}
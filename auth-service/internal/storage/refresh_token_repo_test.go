@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := InitDB(filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := RunMigrations(db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// startTestSession signs alice in with a refresh token whose hash is
+// tokenHash and which expires at expiresAt.
+func startTestSession(t *testing.T, db *sql.DB, tokenHash string, expiresAt time.Time) {
+	t.Helper()
+	if err := CreateUser(db, "alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := GetUserByUsername(db, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StartSession(db, models.Session{ID: "s1", UserID: user.ID, ExpiresAt: expiresAt}, tokenHash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesSession(t *testing.T) {
+	db := openTestDB(t)
+	expiresAt := time.Now().Add(time.Hour)
+	startTestSession(t, db, "h0", expiresAt)
+
+	user, sessionID, err := RotateRefreshToken(db, "h0", "h1", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alice" || sessionID != "s1" {
+		t.Fatalf("RotateRefreshToken() = %s, %q; want alice, s1", user.Username, sessionID)
+	}
+
+	// Presenting the rotated token again gives the theft away
+	_, sessionID, err = RotateRefreshToken(db, "h0", "h2", expiresAt)
+	if !errors.Is(err, ErrRefreshTokenReused) || sessionID != "s1" {
+		t.Fatalf("reusing a rotated token: %q, %v; want s1, ErrRefreshTokenReused", sessionID, err)
+	}
+	session, err := GetSession(db, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.RevokedAt == nil {
+		t.Error("session still active after refresh token reuse")
+	}
+	// The newest token of the family is revoked with it
+	if _, _, err := RotateRefreshToken(db, "h1", "h3", expiresAt); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("rotating the family's newest token: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestRotateRefreshTokenRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		hash      string
+		expiresAt time.Time
+	}{
+		{name: "expired", hash: "h0", expiresAt: time.Now().Add(-time.Minute)},
+		{name: "unknown", hash: "other", expiresAt: time.Now().Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			startTestSession(t, db, "h0", tt.expiresAt)
+
+			if _, _, err := RotateRefreshToken(db, tt.hash, "h1", time.Now().Add(time.Hour)); !errors.Is(err, ErrRefreshTokenInvalid) {
+				t.Fatalf("RotateRefreshToken() error = %v, want ErrRefreshTokenInvalid", err)
+			}
+			// Rejecting a token is not a reason to sign the session out
+			session, err := GetSession(db, "s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.RevokedAt != nil {
+				t.Error("session revoked after rejecting an invalid token")
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenConcurrently(t *testing.T) {
+	db := openTestDB(t)
+	expiresAt := time.Now().Add(time.Hour)
+	startTestSession(t, db, "h0", expiresAt)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, newHash := range []string{"h1", "h2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = RotateRefreshToken(db, "h0", newHash, expiresAt)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d of 2 concurrent rotations succeeded (errors %v), want exactly 1", succeeded, errs)
+	}
+	var issued int
+	if err := db.QueryRow("SELECT COUNT(*) FROM refresh_tokens WHERE token_hash IN ('h1', 'h2')").Scan(&issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 1 {
+		t.Errorf("%d successor tokens stored, want 1", issued)
+	}
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
+)
+
+// ErrSessionNotFound is returned when no session has the requested ID.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limits how often validating a token writes the
+// session's last use time.
+const sessionTouchInterval = time.Minute
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	s := &models.Session{}
+	var revokedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return s, nil
+}
+
+// StartSession records a new login together with its first refresh token.
+func StartSession(db *sql.DB, s models.Session, tokenHash string) error {
+	utils.Info("Starting session: " + s.ID)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec("INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		s.ID, s.UserID, s.UserAgent, s.IP, now, now, s.ExpiresAt.UTC()); err != nil {
+		utils.Error("Failed to store session: " + err.Error())
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		s.UserID, s.ID, tokenHash, s.ExpiresAt.UTC()); err != nil {
+		utils.Error("Failed to store refresh token: " + err.Error())
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSession returns a session, including revoked ones.
+func GetSession(db *sql.DB, id string) (*models.Session, error) {
+	s, err := scanSession(db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		utils.Error("Failed to load session: " + err.Error())
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetActiveSessions lists the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func GetActiveSessions(db *sql.DB, userID int) ([]models.Session, error) {
+	rows, err := db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_used_at DESC",
+		userID, time.Now().UTC())
+	if err != nil {
+		utils.Error("Failed to list sessions: " + err.Error())
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			utils.Error("Failed to close rows: " + err.Error())
+		}
+	}(rows)
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that the session was just used, at most once per
+// sessionTouchInterval.
+func TouchSession(db *sql.DB, id string) error {
+	now := time.Now().UTC()
+	_, err := db.Exec("UPDATE sessions SET last_used_at = ? WHERE id = ? AND last_used_at < ?", now, id, now.Add(-sessionTouchInterval))
+	if err != nil {
+		utils.Error("Failed to touch session: " + err.Error())
+	}
+	return err
+}
+
+// RevokeSession signs a session out: it is marked revoked and every refresh
+// token issued for it stops working.
+func RevokeSession(db *sql.DB, id string) error {
+	utils.Info("Revoking session: " + id)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := revokeSession(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func revokeSession(tx *sql.Tx, id string) error {
+	now := time.Now().UTC()
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now, id); err != nil {
+		utils.Error("Failed to revoke session: " + err.Error())
+		return err
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, id); err != nil {
+		utils.Error("Failed to revoke refresh tokens: " + err.Error())
+		return err
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/models"
 	"github.com/genryusaishigikuni/messenger/auth-service/pkg/utils"
@@ -19,6 +21,17 @@ func CreateUser(db *sql.DB, username, hashedPassword string) error {
 	return nil
 }
 
+// UpdatePasswordHash replaces the user's stored password hash, e.g. after
+// upgrading it to the current hashing parameters.
+func UpdatePasswordHash(db *sql.DB, userID int, hashedPassword string) error {
+	_, err := db.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", hashedPassword, userID)
+	if err != nil {
+		utils.Error("Failed to update password hash: " + err.Error())
+		return err
+	}
+	return nil
+}
+
 func GetUserByUsername(db *sql.DB, username string) (*models.User, error) {
 	utils.Info("Fetching user by username: " + username)
 	row := db.QueryRow("SELECT id, username, hashed_password, created_at FROM users WHERE username = ?", username)
@@ -50,3 +63,58 @@ func UserExists(db *sql.DB, username string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// GetUsersByUsernames returns the users with the given usernames. Unknown
+// usernames are skipped.
+func GetUsersByUsernames(db *sql.DB, usernames []string) ([]models.User, error) {
+	utils.Info("Fetching users by username: " + strings.Join(usernames, ", "))
+	args := make([]interface{}, len(usernames))
+	for i, username := range usernames {
+		args[i] = username
+	}
+	return getUsersWhereIn(db, "username", args)
+}
+
+// GetUsersByIDs returns the users with the given IDs. Unknown IDs are
+// skipped.
+func GetUsersByIDs(db *sql.DB, ids []int) ([]models.User, error) {
+	utils.Info("Fetching users by ID: " + strconv.Itoa(len(ids)) + " IDs")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return getUsersWhereIn(db, "id", args)
+}
+
+// getUsersWhereIn returns the users whose column matches one of values.
+func getUsersWhereIn(db *sql.DB, column string, values []interface{}) ([]models.User, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = "?"
+	}
+
+	rows, err := db.Query("SELECT id, username, created_at FROM users WHERE "+column+" IN ("+strings.Join(placeholders, ", ")+") ORDER BY id", values...)
+	if err != nil {
+		utils.Error("Failed to fetch users: " + err.Error())
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			utils.Error("Failed to close rows: " + err.Error())
+		}
+	}(rows)
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt); err != nil {
+			utils.Error("Failed to scan user: " + err.Error())
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS sessions (
+                                        id TEXT PRIMARY KEY,
+                                        user_id INTEGER NOT NULL,
+                                        user_agent TEXT NOT NULL DEFAULT '',
+                                        ip TEXT NOT NULL DEFAULT '',
+                                        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                        last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                        expires_at DATETIME NOT NULL,
+                                        revoked_at DATETIME,
+                                        FOREIGN KEY(user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Logins made before sessions were recorded are known only by their refresh token family
+INSERT OR IGNORE INTO sessions (id, user_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT family_id, user_id, MIN(created_at), MAX(created_at), MAX(expires_at), MAX(revoked_at)
+FROM refresh_tokens GROUP BY family_id;
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session is one login. Its ID is the sid claim of the access tokens and
+// the family of the refresh tokens issued for it.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"` // when its current refresh token expires
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // the session of the requesting token
+}
@@ -7,5 +7,8 @@ import (
 type TokenClaims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	// SessionID ties the token to the login it was issued for, so that
+	// signing out revokes it. Tokens without one are rejected.
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
@@ -2,12 +2,39 @@ package utils
 
 import (
 	"os"
+	"runtime"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	DatabasePath string
-	JWTSecret    string
-	ServerPort   string
+	DatabasePath    string
+	ServerPort      string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// KeysDir holds the PEM-encoded signing keys; one is generated when it
+	// is empty.
+	KeysDir             string
+	SigningAlgorithm    string
+	KeyRotationInterval time.Duration
+
+	PasswordMinLength  int
+	PasswordMaxLength  int
+	PasswordMinClasses int
+	// PasswordDenyListPath is a file of common passwords, one per line,
+	// that may not be used for new accounts. An empty path disables it.
+	PasswordDenyListPath string
+	// PasswordDenyListRequired is set when the path was configured
+	// explicitly, in which case a missing file stops startup.
+	PasswordDenyListRequired bool
+	// Argon2 parameters for password hashes. Existing hashes made with
+	// other parameters are upgraded on the user's next login.
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	// Argon2MaxConcurrency caps how many passwords are hashed at once, and
+	// with it the memory hashing can take: Argon2Memory KiB per hash.
+	Argon2MaxConcurrency int
 }
 
 func LoadConfig() Config {
@@ -15,9 +42,17 @@ func LoadConfig() Config {
 	if dbPath == "" {
 		dbPath = "./auth.db"
 	}
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret"
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		keysDir = "./keys"
+	}
+	denyListPath, denyListSet := os.LookupEnv("PASSWORD_DENYLIST_FILE")
+	if !denyListSet {
+		denyListPath = "./common-passwords.txt"
+	}
+	signingAlg := os.Getenv("JWT_SIGNING_ALG")
+	if signingAlg == "" {
+		signingAlg = "EdDSA"
 	}
 	port := os.Getenv("AUTH_SERVICE_PORT")
 	if port == "" {
@@ -25,8 +60,53 @@ func LoadConfig() Config {
 	}
 
 	return Config{
-		DatabasePath: dbPath,
-		JWTSecret:    jwtSecret,
-		ServerPort:   port,
+		DatabasePath:    dbPath,
+		ServerPort:      port,
+		AccessTokenTTL:  durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		KeysDir:             keysDir,
+		SigningAlgorithm:    signingAlg,
+		KeyRotationInterval: durationFromEnv("JWT_KEY_ROTATION_INTERVAL", 7*24*time.Hour),
+
+		PasswordMinLength:        intFromEnv("PASSWORD_MIN_LENGTH", 12),
+		PasswordMaxLength:        intFromEnv("PASSWORD_MAX_LENGTH", 128),
+		PasswordMinClasses:       intFromEnv("PASSWORD_MIN_CLASSES", 3),
+		PasswordDenyListPath:     denyListPath,
+		PasswordDenyListRequired: denyListSet,
+		Argon2Memory:             uint32(intFromEnv("ARGON2_MEMORY_KIB", 64*1024)),
+		Argon2Iterations:         uint32(intFromEnv("ARGON2_ITERATIONS", 3)),
+		Argon2Parallelism:        uint8(min(intFromEnv("ARGON2_PARALLELISM", 2), 255)),
+		Argon2MaxConcurrency:     intFromEnv("ARGON2_MAX_CONCURRENCY", runtime.NumCPU()),
+	}
+}
+
+// durationFromEnv parses a duration such as "15m" from the environment,
+// falling back to def when the variable is unset or malformed.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		Error("Invalid duration for " + key + ": " + raw)
+		return def
+	}
+	return d
+}
+
+// intFromEnv parses a positive integer from the environment, falling back
+// to def when the variable is unset or malformed.
+func intFromEnv(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		Error("Invalid integer for " + key + ": " + raw)
+		return def
 	}
+	return n
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/genryusaishigikuni/messenger/gateway-service/internal/handlers"
 	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
 	"github.com/gorilla/mux"
 )
 
@@ -14,18 +16,45 @@ func main() {
 	cfg := utils.LoadConfig()
 
 	utils.Info("Initializing connection manager")
-	manager := handlers.NewConnectionManager()
+	manager := handlers.NewConnectionManager(handlers.ManagerConfig{
+		SendQueueSize:  cfg.SendQueueSize,
+		OverflowPolicy: cfg.SlowConsumerPolicy,
+		PingInterval:   cfg.PingInterval,
+		PongTimeout:    cfg.PongTimeout,
+		MaxIdleTime:    cfg.MaxIdleTime,
+
+		TypingTimeout:   cfg.TypingTimeout,
+		TypingRateLimit: cfg.TypingRateLimit,
+
+		ReplayLimit: cfg.ReplayLimit,
+
+		PresenceServiceURL: cfg.PresenceServiceURL,
+		InternalAPIToken:   cfg.InternalAPIToken,
+	})
 
 	utils.Info("Setting up router")
 	r := mux.NewRouter()
 
 	utils.Info("Registering WebSocket endpoint")
 	// WebSocket endpoint
-	r.HandleFunc("/ws", handlers.WebSocketHandler(manager, cfg.AuthServiceURL, cfg.MessageServiceURL))
+	verifier := tokenverify.New(cfg.AuthServiceURL)
+	r.HandleFunc("/ws", handlers.WebSocketHandler(manager, verifier, cfg.MessageServiceURL))
 
 	utils.Info("Registering Presence event endpoint")
 	// Presence event endpoint (called by Presence Service)
-	r.HandleFunc("/api/presence/event", handlers.PresenceEventHandler(manager)).Methods("POST")
+	r.HandleFunc("/api/presence/event", internalauth.Require(cfg.InternalAPIToken, handlers.PresenceEventHandler(manager))).Methods("POST")
+
+	utils.Info("Registering channel event endpoint")
+	// Channel event endpoint (called by Message Service)
+	r.HandleFunc("/api/events", internalauth.Require(cfg.InternalAPIToken, handlers.ChannelEventHandler(manager))).Methods("POST")
+
+	utils.Info("Registering session revoked endpoint")
+	// Session revocation endpoint (called by Auth Service): tokens of the
+	// session stop verifying and its open sockets are closed
+	r.HandleFunc("/api/sessions/revoked", internalauth.Require(cfg.InternalAPIToken, verifier.RevocationHandler(manager.DisconnectSession))).Methods("POST")
+
+	utils.Info("Registering metrics endpoint")
+	r.HandleFunc("/api/metrics", handlers.MetricsHandler(manager)).Methods("GET")
 
 	// Setting up middleware for CORS
 	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -3,6 +3,9 @@ module github.com/genryusaishigikuni/messenger/gateway-service
 go 1.23.4
 
 require (
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/genryusaishigikuni/messenger/tokenverify v0.0.0
+	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
 )
+
+replace github.com/genryusaishigikuni/messenger/tokenverify => ../tokenverify
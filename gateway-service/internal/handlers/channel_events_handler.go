@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+)
+
+type channelEventRequest struct {
+	Event     string          `json:"event"` // frame type delivered to clients, e.g. "message_edited"
+	ChannelID int             `json:"channel_id"`
+	Payload   json.RawMessage `json:"payload"`
+	UserIDs   []int           `json:"user_ids,omitempty"` // deliver to these users instead of the channel's subscribers
+}
+
+// ChannelEventHandler relays events from the Message Service to every client
+// subscribed to the event's channel, or to every connection of the listed
+// users when the event names any. A member_removed event also drops the
+// removed user's subscriptions once they have been told about it.
+func ChannelEventHandler(manager *ConnectionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received channel event request")
+
+		var ev channelEventRequest
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			utils.Error("Failed to decode channel event request: " + err.Error())
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if ev.Event == "" || ev.ChannelID < 1 {
+			utils.Error("Channel event is missing event type or channel ID")
+			http.Error(w, "event and channel_id are required", http.StatusBadRequest)
+			return
+		}
+
+		if len(ev.UserIDs) > 0 {
+			utils.Info("Sending channel event: " + ev.Event + " in ChannelID=" + strconv.Itoa(ev.ChannelID) + " to " + strconv.Itoa(len(ev.UserIDs)) + " users")
+			manager.SendToUsers(ev.UserIDs, ev.Event, ev.Payload)
+		} else {
+			utils.Info("Broadcasting channel event: " + ev.Event + " in ChannelID=" + strconv.Itoa(ev.ChannelID))
+			manager.BroadcastEvent(ev.ChannelID, ev.Event, ev.Payload)
+		}
+
+		if ev.Event == models.FrameMemberRemoved {
+			var member models.MemberPayload
+			if err := json.Unmarshal(ev.Payload, &member); err != nil {
+				utils.Error("Failed to decode member_removed payload: " + err.Error())
+			} else {
+				manager.UnsubscribeUser(member.UserID, ev.ChannelID)
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"message":"received"}`))
+		if err != nil {
+			utils.Error("Failed to send response: " + err.Error())
+		}
+	}
+}
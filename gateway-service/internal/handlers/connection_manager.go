@@ -3,55 +3,438 @@ package handlers
 import (
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/genryusaishigikuni/messenger/gateway-service/internal/presenceclient"
 	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/models"
 	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
 	"github.com/gorilla/websocket"
 )
 
+// Policies applied when a client's outbound queue is full.
+const (
+	PolicyDropOldest = "drop_oldest"
+	PolicyDisconnect = "disconnect"
+)
+
+// writeWait bounds how long a single frame write may block.
+const writeWait = 10 * time.Second
+
+// ManagerConfig controls per-connection outbound queueing and liveness checks.
+type ManagerConfig struct {
+	SendQueueSize  int
+	OverflowPolicy string
+	PingInterval   time.Duration // how often the server pings each client
+	PongTimeout    time.Duration // how long a client may stay silent before it is considered dead
+	MaxIdleTime    time.Duration // how long a client may go without sending a frame; 0 disables
+
+	TypingTimeout   time.Duration // how long a typing indicator lasts without being refreshed
+	TypingRateLimit int           // typing frames a connection may send per second
+
+	ReplayLimit int // most messages replayed on subscribe; clients page through the rest over REST
+
+	PresenceServiceURL string // presence service notified when a user's first socket opens or last socket closes
+	InternalAPIToken   string // authenticates leave notifications, which must not depend on the user's access token
+}
+
+// presenceUpdate is a join or leave notification queued for the presence
+// service. Joins carry the token the socket was opened with; leaves go out
+// by user ID because that token may have expired by then.
+type presenceUpdate struct {
+	online bool
+	userID int
+	token  string
+}
+
 type clientInfo struct {
 	Conn      *websocket.Conn
 	UserID    int
+	SessionID string // auth session of Token; revoking it closes the connection
 	Token     string
-	ChannelID int
+	Channels  map[int]bool // channel IDs this connection is subscribed to
+	send      chan []byte  // outbound frames, drained by writePump
+
+	// replayMu guards pending, which holds live messages for channels whose
+	// history is still being replayed to this connection.
+	replayMu sync.Mutex
+	pending  map[int][]models.Message
+
+	lastActivity atomic.Int64 // unix nanos of the last frame received from the client
+}
+
+// ManagerStats is a snapshot of the connection manager counters.
+type ManagerStats struct {
+	ConnectedClients          int    `json:"connected_clients"`
+	SendQueueSize             int    `json:"send_queue_size"`
+	OverflowPolicy            string `json:"overflow_policy"`
+	FramesDropped             int64  `json:"frames_dropped"`
+	SlowConsumersDisconnected int64  `json:"slow_consumers_disconnected"`
+	PresenceSyncFailures      int64  `json:"presence_sync_failures"`
 }
 
-// ConnectionManager tracks channels and their connected clients
+// ConnectionManager tracks connected clients and the channels they subscribe to
 type ConnectionManager struct {
-	mu       sync.RWMutex
-	channels map[int]map[*websocket.Conn]*clientInfo // channel_id -> map[conn]*clientInfo
+	mu        sync.RWMutex
+	cfg       ManagerConfig
+	clients   map[*websocket.Conn]*clientInfo         // conn -> clientInfo
+	channels  map[int]map[*websocket.Conn]*clientInfo // channel_id -> map[conn]*clientInfo
+	userConns map[int]int                             // user_id -> number of live connections
+
+	// presenceUpdates is drained by a single goroutine so that join and leave
+	// notifications reach the presence service in the order they happened.
+	presenceUpdates chan presenceUpdate
+
+	typingMu sync.Mutex
+	typing   map[typingKey]*typingState
+
+	framesDropped             atomic.Int64
+	slowConsumersDisconnected atomic.Int64
+	presenceSyncFailures      atomic.Int64
 }
 
-func NewConnectionManager() *ConnectionManager {
+func NewConnectionManager(cfg ManagerConfig) *ConnectionManager {
 	utils.Info("Initializing Connection Manager")
-	return &ConnectionManager{
-		channels: make(map[int]map[*websocket.Conn]*clientInfo),
+	if cfg.SendQueueSize < 1 {
+		cfg.SendQueueSize = 64
+	}
+	if cfg.OverflowPolicy != PolicyDisconnect {
+		cfg.OverflowPolicy = PolicyDropOldest
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = 30 * time.Second
+	}
+	if cfg.PongTimeout <= cfg.PingInterval {
+		cfg.PongTimeout = cfg.PingInterval * 2
+	}
+	if cfg.TypingTimeout <= 0 {
+		cfg.TypingTimeout = 5 * time.Second
 	}
+	if cfg.TypingRateLimit < 1 {
+		cfg.TypingRateLimit = 5
+	}
+	if cfg.ReplayLimit < 1 {
+		cfg.ReplayLimit = 500
+	}
+	utils.Info("Outbound queue size: " + strconv.Itoa(cfg.SendQueueSize) + ", overflow policy: " + cfg.OverflowPolicy)
+	utils.Info("Ping interval: " + cfg.PingInterval.String() + ", pong timeout: " + cfg.PongTimeout.String() + ", max idle: " + cfg.MaxIdleTime.String())
+
+	m := &ConnectionManager{
+		cfg:             cfg,
+		clients:         make(map[*websocket.Conn]*clientInfo),
+		channels:        make(map[int]map[*websocket.Conn]*clientInfo),
+		userConns:       make(map[int]int),
+		presenceUpdates: make(chan presenceUpdate, 256),
+		typing:          make(map[typingKey]*typingState),
+	}
+	go m.syncPresence()
+	if cfg.MaxIdleTime > 0 {
+		go m.reapIdleClients()
+	}
+	return m
 }
 
-func (m *ConnectionManager) RegisterClient(conn *websocket.Conn, userID, channelID int, token string) {
+// RegisterClient starts tracking the connection. The first connection of a
+// user marks them online in the presence service.
+func (m *ConnectionManager) RegisterClient(conn *websocket.Conn, userID int, sessionID, token string) {
 	utils.Info("Registering new client")
 	m.mu.Lock()
+
+	client := &clientInfo{
+		Conn:      conn,
+		UserID:    userID,
+		SessionID: sessionID,
+		Token:     token,
+		Channels:  make(map[int]bool),
+		send:      make(chan []byte, m.cfg.SendQueueSize),
+		pending:   make(map[int][]models.Message),
+	}
+	client.lastActivity.Store(time.Now().UnixNano())
+	m.clients[conn] = client
+	m.userConns[userID]++
+	firstConn := m.userConns[userID] == 1
+	go m.writePump(client)
+	m.mu.Unlock()
+
+	utils.Info("Client registered: UserID=" + strconv.Itoa(userID))
+	if firstConn {
+		m.presenceUpdates <- presenceUpdate{online: true, userID: userID, token: token}
+	}
+}
+
+// syncPresence forwards queued join/leave notifications to the presence service.
+func (m *ConnectionManager) syncPresence() {
+	for update := range m.presenceUpdates {
+		var err error
+		if update.online {
+			err = presenceclient.Join(m.cfg.PresenceServiceURL, update.token, 0)
+		} else {
+			err = presenceclient.LeaveUser(m.cfg.PresenceServiceURL, m.cfg.InternalAPIToken, update.userID)
+		}
+		if err != nil {
+			m.presenceSyncFailures.Add(1)
+			utils.Error("Failed to sync presence for user " + strconv.Itoa(update.userID) + " (online=" + strconv.FormatBool(update.online) + "): " + err.Error())
+		}
+	}
+}
+
+// writePump is the only goroutine that writes to the connection. Besides
+// draining the send queue it pings the client every PingInterval.
+func (m *ConnectionManager) writePump(client *clientInfo) {
+	ticker := time.NewTicker(m.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		messageType := websocket.TextMessage
+		var data []byte
+		select {
+		case frame, ok := <-client.send:
+			if !ok {
+				return
+			}
+			data = frame
+		case <-ticker.C:
+			messageType = websocket.PingMessage
+		}
+
+		if err := client.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			utils.Error("Failed to set write deadline: " + err.Error())
+		}
+		if err := client.Conn.WriteMessage(messageType, data); err != nil {
+			utils.Error("Failed to write frame to client: " + err.Error())
+			m.UnregisterClient(client.Conn)
+			return
+		}
+	}
+}
+
+// ExtendReadDeadline pushes back the point at which a silent client is
+// considered dead. It is called for every frame and pong received.
+func (m *ConnectionManager) ExtendReadDeadline(conn *websocket.Conn) error {
+	return conn.SetReadDeadline(time.Now().Add(m.cfg.PongTimeout))
+}
+
+// Touch records application-level activity from the client.
+func (m *ConnectionManager) Touch(conn *websocket.Conn) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if client, ok := m.clients[conn]; ok {
+		client.lastActivity.Store(time.Now().UnixNano())
+	}
+}
+
+// reapIdleClients periodically unregisters clients that have not sent a
+// frame within MaxIdleTime. Pongs keep a connection alive but do not count
+// as activity.
+func (m *ConnectionManager) reapIdleClients() {
+	ticker := time.NewTicker(m.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-m.cfg.MaxIdleTime).UnixNano()
+
+		var idle []*websocket.Conn
+		m.mu.RLock()
+		for conn, client := range m.clients {
+			if client.lastActivity.Load() < cutoff {
+				idle = append(idle, conn)
+			}
+		}
+		m.mu.RUnlock()
+
+		for _, conn := range idle {
+			utils.Info("Reaping idle client connection")
+			m.UnregisterClient(conn)
+		}
+	}
+}
+
+// enqueue hands a frame to the client's write pump without blocking.
+// Must be called with m.mu held (read or write).
+func (m *ConnectionManager) enqueue(client *clientInfo, data []byte) {
+	select {
+	case client.send <- data:
+		return
+	default:
+	}
+
+	if m.cfg.OverflowPolicy == PolicyDisconnect {
+		m.framesDropped.Add(1)
+		m.slowConsumersDisconnected.Add(1)
+		utils.Error("Outbound queue full; disconnecting slow consumer UserID=" + strconv.Itoa(client.UserID))
+		// UnregisterClient needs the write lock, which the caller may be holding for reading.
+		go m.UnregisterClient(client.Conn)
+		return
+	}
+
+	// Drop the oldest queued frame to make room for the new one.
+	select {
+	case <-client.send:
+		m.framesDropped.Add(1)
+	default:
+	}
+	select {
+	case client.send <- data:
+	default:
+		m.framesDropped.Add(1)
+	}
+	utils.Error("Outbound queue full; dropped oldest frame for UserID=" + strconv.Itoa(client.UserID))
+}
+
+// Subscribe adds the channel to the connection's subscription set.
+// It returns false if the connection is not registered.
+func (m *ConnectionManager) Subscribe(conn *websocket.Conn, channelID int) bool {
+	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	client, ok := m.clients[conn]
+	if !ok {
+		utils.Error("Cannot subscribe unknown client to channel ID: " + strconv.Itoa(channelID))
+		return false
+	}
+	m.subscribeLocked(client, channelID)
+	return true
+}
+
+// SubscribeWithReplay subscribes the connection but holds back live messages
+// for the channel until FinishReplay has delivered the missed history.
+func (m *ConnectionManager) SubscribeWithReplay(conn *websocket.Conn, channelID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client, ok := m.clients[conn]
+	if !ok {
+		utils.Error("Cannot subscribe unknown client to channel ID: " + strconv.Itoa(channelID))
+		return false
+	}
+
+	client.replayMu.Lock()
+	if _, replaying := client.pending[channelID]; !replaying {
+		client.pending[channelID] = []models.Message{}
+	}
+	client.replayMu.Unlock()
+
+	m.subscribeLocked(client, channelID)
+	return true
+}
+
+// FinishReplay queues the replayed history followed by any live messages
+// held back since SubscribeWithReplay, then resumes live delivery. A
+// truncated history is flagged so the client can fetch the gap between it
+// and the live messages over REST.
+func (m *ConnectionManager) FinishReplay(conn *websocket.Conn, channelID int, history []models.Message, truncated bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.clients[conn]
+	if !ok {
+		return
+	}
+
+	client.replayMu.Lock()
+	defer client.replayMu.Unlock()
+
+	held := client.pending[channelID]
+	delete(client.pending, channelID)
+
+	lastID := 0
+	if len(history) > 0 {
+		lastID = history[len(history)-1].ID
+		payload := models.HistoryPayload{ChannelID: channelID, Messages: history}
+		if truncated {
+			payload.Truncated = true
+			payload.NextCursor = &lastID
+		}
+		historyBytes, err := models.MarshalEnvelope(models.FrameHistory, "", payload)
+		if err != nil {
+			utils.Error("Failed to marshal history: " + err.Error())
+		} else {
+			m.enqueue(client, historyBytes)
+		}
+	}
+
+	for _, msg := range held {
+		if msg.ID <= lastID {
+			continue
+		}
+		msgBytes, err := models.MarshalEnvelope(models.FrameMessage, "", msg)
+		if err != nil {
+			utils.Error("Failed to marshal message: " + err.Error())
+			continue
+		}
+		m.enqueue(client, msgBytes)
+	}
+	utils.Info("Replay finished for channel ID: " + strconv.Itoa(channelID))
+}
+
+// holdForReplay buffers msg if the client is still replaying the channel.
+func (client *clientInfo) holdForReplay(channelID int, msg models.Message) bool {
+	client.replayMu.Lock()
+	defer client.replayMu.Unlock()
+
+	held, replaying := client.pending[channelID]
+	if !replaying {
+		return false
+	}
+	client.pending[channelID] = append(held, msg)
+	return true
+}
+
+// subscribeLocked must be called with m.mu held.
+func (m *ConnectionManager) subscribeLocked(client *clientInfo, channelID int) {
+	conn := client.Conn
 	if _, ok := m.channels[channelID]; !ok {
 		utils.Info("Creating a new channel for channel ID: " + strconv.Itoa(channelID))
 		m.channels[channelID] = make(map[*websocket.Conn]*clientInfo)
 	}
-	m.channels[channelID][conn] = &clientInfo{
-		Conn:      conn,
-		UserID:    userID,
-		ChannelID: channelID,
-		Token:     token,
+	m.channels[channelID][conn] = client
+	client.Channels[channelID] = true
+	utils.Info("Client subscribed: UserID=" + strconv.Itoa(client.UserID) + ", ChannelID=" + strconv.Itoa(channelID))
+}
+
+// Unsubscribe removes the channel from the connection's subscription set.
+// The user's typing indicator there is cleared once none of their
+// connections remain subscribed.
+func (m *ConnectionManager) Unsubscribe(conn *websocket.Conn, channelID int) {
+	m.mu.Lock()
+	client, ok := m.clients[conn]
+	if ok {
+		delete(client.Channels, channelID)
+		client.replayMu.Lock()
+		delete(client.pending, channelID)
+		client.replayMu.Unlock()
+		utils.Info("Client unsubscribed: UserID=" + strconv.Itoa(client.UserID) + ", ChannelID=" + strconv.Itoa(channelID))
+	}
+	m.removeFromChannel(conn, channelID)
+	left := ok && !m.userSubscribedLocked(client.UserID, channelID)
+	m.mu.Unlock()
+
+	if left {
+		m.StopTyping(client.UserID, channelID)
 	}
-	utils.Info("Client registered: UserID=" + strconv.Itoa(userID) + ", ChannelID=" + strconv.Itoa(channelID))
 }
 
-func (m *ConnectionManager) UnregisterClient(conn *websocket.Conn, channelID int) {
-	utils.Info("Unregistering client")
+// UnsubscribeUser removes the channel from every connection of the user,
+// e.g. after they were removed from the channel's members.
+func (m *ConnectionManager) UnsubscribeUser(userID, channelID int) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	var conns []*websocket.Conn
+	for conn, client := range m.channels[channelID] {
+		if client.UserID == userID {
+			conns = append(conns, conn)
+		}
+	}
+	m.mu.Unlock()
 
+	for _, conn := range conns {
+		m.Unsubscribe(conn, channelID)
+	}
+	utils.Info("Unsubscribed UserID=" + strconv.Itoa(userID) + " from ChannelID=" + strconv.Itoa(channelID))
+}
+
+// removeFromChannel must be called with m.mu held.
+func (m *ConnectionManager) removeFromChannel(conn *websocket.Conn, channelID int) {
 	if channel, ok := m.channels[channelID]; ok {
 		delete(channel, conn)
 		if len(channel) == 0 {
@@ -59,6 +442,41 @@ func (m *ConnectionManager) UnregisterClient(conn *websocket.Conn, channelID int
 			delete(m.channels, channelID)
 		}
 	}
+}
+
+// UnregisterClient removes the connection, closes it and emits user_left to
+// every channel the user is no longer subscribed to on any other connection.
+// Closing the user's last connection marks them offline in the presence service.
+func (m *ConnectionManager) UnregisterClient(conn *websocket.Conn) {
+	utils.Info("Unregistering client")
+	m.mu.Lock()
+
+	client, ok := m.clients[conn]
+	if !ok {
+		m.mu.Unlock()
+		utils.Info("Client already unregistered")
+		return
+	}
+	for channelID := range client.Channels {
+		m.removeFromChannel(conn, channelID)
+	}
+	delete(m.clients, conn)
+	// Every sender looks the client up under m.mu, so nothing can enqueue after this.
+	close(client.send)
+
+	m.userConns[client.UserID]--
+	lastConn := m.userConns[client.UserID] <= 0
+	if lastConn {
+		delete(m.userConns, client.UserID)
+	}
+
+	var leftChannels []int
+	for channelID := range client.Channels {
+		if !m.userSubscribedLocked(client.UserID, channelID) {
+			leftChannels = append(leftChannels, channelID)
+		}
+	}
+	m.mu.Unlock()
 
 	err := conn.Close()
 	if err != nil {
@@ -66,6 +484,25 @@ func (m *ConnectionManager) UnregisterClient(conn *websocket.Conn, channelID int
 	} else {
 		utils.Info("WebSocket connection closed")
 	}
+
+	for _, channelID := range leftChannels {
+		m.StopTyping(client.UserID, channelID)
+		m.BroadcastPresenceEvent("user_left", client.UserID, channelID)
+	}
+	if lastConn {
+		m.presenceUpdates <- presenceUpdate{online: false, userID: client.UserID}
+	}
+}
+
+// userSubscribedLocked reports whether any connection of the user is
+// subscribed to the channel. Must be called with m.mu held.
+func (m *ConnectionManager) userSubscribedLocked(userID, channelID int) bool {
+	for _, client := range m.channels[channelID] {
+		if client.UserID == userID {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *ConnectionManager) BroadcastToChannel(channelID int, msg models.Message) {
@@ -79,68 +516,178 @@ func (m *ConnectionManager) BroadcastToChannel(channelID int, msg models.Message
 		return
 	}
 
-	msgBytes, err := msg.MarshalJSON()
+	msgBytes, err := models.MarshalEnvelope(models.FrameMessage, "", msg)
 	if err != nil {
 		utils.Error("Failed to marshal message: " + err.Error())
 		return
 	}
 
 	for _, client := range channel {
-		err := client.Conn.WriteMessage(websocket.TextMessage, msgBytes)
-		if err != nil {
-			utils.Error("Failed to send message to client: " + err.Error())
+		if client.holdForReplay(channelID, msg) {
+			continue
 		}
+		m.enqueue(client, msgBytes)
 	}
 	utils.Info("Message broadCasted successfully to channel: " + strconv.Itoa(channelID))
 }
 
 func (m *ConnectionManager) BroadcastPresenceEvent(eventType string, userID, channelID int) {
 	utils.Info("Broadcasting presence event: " + eventType)
-	event := map[string]interface{}{
-		"event":      eventType,
-		"user_id":    userID,
-		"channel_id": channelID,
+	event := models.PresencePayload{
+		Event:     eventType,
+		UserID:    userID,
+		ChannelID: channelID,
 	}
 
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	channel, ok := m.channels[channelID]
+	if channelID == 0 {
+		// Online/offline changes are not tied to a channel and go to everyone
+		channel, ok = m.clients, true
+	}
 	if !ok {
 		utils.Error("Channel not found for ID: " + strconv.Itoa(channelID))
 		return
 	}
 
-	eventBytes, err := models.MarshalJSONGeneric(event)
+	eventBytes, err := models.MarshalEnvelope(models.FramePresence, "", event)
 	if err != nil {
 		utils.Error("Failed to marshal presence event: " + err.Error())
 		return
 	}
 
 	for _, client := range channel {
-		err := client.Conn.WriteMessage(websocket.TextMessage, eventBytes)
-		if err != nil {
-			utils.Error("Failed to send presence event to client: " + err.Error())
-		}
+		m.enqueue(client, eventBytes)
 	}
 	utils.Info("Presence event broadCasted successfully to channel: " + strconv.Itoa(channelID))
 }
 
-func (m *ConnectionManager) GetTokenForClient(conn *websocket.Conn, channelID int) string {
-	utils.Info("Fetching token for client in channel ID: " + strconv.Itoa(channelID))
+// BroadcastEvent sends an arbitrary frame to every client subscribed to the channel.
+func (m *ConnectionManager) BroadcastEvent(channelID int, frameType string, payload interface{}) {
+	utils.Info("Broadcasting " + frameType + " event to channel: " + strconv.Itoa(channelID))
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	channel, ok := m.channels[channelID]
 	if !ok {
-		utils.Error("Channel not found for ID: " + strconv.Itoa(channelID))
-		return ""
+		utils.Info("No subscribers for channel ID: " + strconv.Itoa(channelID))
+		return
+	}
+
+	eventBytes, err := models.MarshalEnvelope(frameType, "", payload)
+	if err != nil {
+		utils.Error("Failed to marshal " + frameType + " event: " + err.Error())
+		return
+	}
+
+	for _, client := range channel {
+		m.enqueue(client, eventBytes)
+	}
+	utils.Info(frameType + " event broadcasted successfully to channel: " + strconv.Itoa(channelID))
+}
+
+// SendToUsers sends a frame to every connection of the given users,
+// regardless of their subscriptions.
+func (m *ConnectionManager) SendToUsers(userIDs []int, frameType string, payload interface{}) {
+	eventBytes, err := models.MarshalEnvelope(frameType, "", payload)
+	if err != nil {
+		utils.Error("Failed to marshal " + frameType + " event: " + err.Error())
+		return
 	}
-	client, ok := channel[conn]
+
+	targets := make(map[int]bool, len(userIDs))
+	for _, userID := range userIDs {
+		targets[userID] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sent := 0
+	for _, client := range m.clients {
+		if targets[client.UserID] {
+			m.enqueue(client, eventBytes)
+			sent++
+		}
+	}
+	utils.Info(frameType + " event sent to " + strconv.Itoa(sent) + " connections")
+}
+
+func (m *ConnectionManager) GetTokenForClient(conn *websocket.Conn) string {
+	utils.Info("Fetching token for client")
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.clients[conn]
 	if !ok {
-		utils.Error("Client connection not found in channel ID: " + strconv.Itoa(channelID))
+		utils.Error("Client connection not found")
 		return ""
 	}
 	utils.Info("Token fetched successfully for client")
 	return client.Token
 }
+
+// SetTokenForClient replaces the token used for the client's upstream calls.
+func (m *ConnectionManager) SetTokenForClient(conn *websocket.Conn, sessionID, token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if client, ok := m.clients[conn]; ok {
+		client.SessionID = sessionID
+		client.Token = token
+		utils.Info("Token replaced for client: UserID=" + strconv.Itoa(client.UserID))
+	}
+}
+
+// DisconnectSession closes every connection opened with a token of the
+// session, telling the clients why before dropping them.
+func (m *ConnectionManager) DisconnectSession(sessionID string) {
+	var conns []*websocket.Conn
+	m.mu.RLock()
+	for conn, client := range m.clients {
+		if client.SessionID == sessionID {
+			conns = append(conns, conn)
+		}
+	}
+	m.mu.RUnlock()
+
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for _, conn := range conns {
+		// WriteControl may run concurrently with the write pump
+		if err := conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(writeWait)); err != nil {
+			utils.Error("Failed to send close frame: " + err.Error())
+		}
+		m.UnregisterClient(conn)
+	}
+	utils.Info("Disconnected " + strconv.Itoa(len(conns)) + " connections of revoked session")
+}
+
+// SendToClient queues a single frame for one connection.
+func (m *ConnectionManager) SendToClient(conn *websocket.Conn, data []byte) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.clients[conn]
+	if !ok {
+		utils.Error("Client connection not found")
+		return
+	}
+	m.enqueue(client, data)
+}
+
+// Stats returns a snapshot of the manager's queueing counters.
+func (m *ConnectionManager) Stats() ManagerStats {
+	m.mu.RLock()
+	connected := len(m.clients)
+	m.mu.RUnlock()
+
+	return ManagerStats{
+		ConnectedClients:          connected,
+		SendQueueSize:             m.cfg.SendQueueSize,
+		OverflowPolicy:            m.cfg.OverflowPolicy,
+		FramesDropped:             m.framesDropped.Load(),
+		SlowConsumersDisconnected: m.slowConsumersDisconnected.Load(),
+		PresenceSyncFailures:      m.presenceSyncFailures.Load(),
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+)
+
+// MetricsHandler reports connection and outbound queue counters.
+func MetricsHandler(manager *ConnectionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received metrics request")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(manager.Stats()); err != nil {
+			utils.Error("Failed to encode metrics response: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+	"github.com/gorilla/websocket"
+)
+
+var errNotSubscribed = errors.New("not subscribed to channel")
+
+// typingKey identifies one user typing in one channel, across all of the
+// user's connections.
+type typingKey struct {
+	channelID int
+	userID    int
+}
+
+// typingState is an active typing indicator. It is cleared by typing_stop,
+// by the user posting or leaving the channel, or by its timer expiring.
+type typingState struct {
+	timer         *time.Timer
+	lastBroadcast time.Time
+}
+
+// StartTyping marks the connection's user as typing in the channel and
+// tells the channel's other subscribers. Repeated starts only extend the
+// indicator; they are re-broadcast at most once per half timeout so that
+// clients relying on expires_in_ms keep showing it.
+func (m *ConnectionManager) StartTyping(conn *websocket.Conn, channelID int) error {
+	m.mu.RLock()
+	client, ok := m.clients[conn]
+	subscribed := ok && client.Channels[channelID]
+	m.mu.RUnlock()
+	if !subscribed {
+		return errNotSubscribed
+	}
+
+	key := typingKey{channelID: channelID, userID: client.UserID}
+	m.typingMu.Lock()
+	defer m.typingMu.Unlock()
+
+	state, ok := m.typing[key]
+	if !ok {
+		state = &typingState{}
+		state.timer = time.AfterFunc(m.cfg.TypingTimeout, func() { m.expireTyping(key, state) })
+		m.typing[key] = state
+	} else {
+		state.timer.Reset(m.cfg.TypingTimeout)
+		if time.Since(state.lastBroadcast) < m.cfg.TypingTimeout/2 {
+			return nil
+		}
+	}
+
+	state.lastBroadcast = time.Now()
+	m.broadcastTyping(models.FrameTypingStart, models.TypingPayload{
+		ChannelID:   channelID,
+		UserID:      client.UserID,
+		ExpiresInMs: int(m.cfg.TypingTimeout / time.Millisecond),
+	})
+	return nil
+}
+
+// StopTyping clears the user's typing indicator in the channel, if any, and
+// tells the channel's other subscribers.
+func (m *ConnectionManager) StopTyping(userID, channelID int) {
+	key := typingKey{channelID: channelID, userID: userID}
+	m.typingMu.Lock()
+	defer m.typingMu.Unlock()
+
+	state, ok := m.typing[key]
+	if !ok {
+		return
+	}
+	state.timer.Stop()
+	delete(m.typing, key)
+	m.broadcastTyping(models.FrameTypingStop, models.TypingPayload{ChannelID: channelID, UserID: userID})
+}
+
+// expireTyping runs when an indicator was not refreshed within the timeout.
+func (m *ConnectionManager) expireTyping(key typingKey, state *typingState) {
+	m.typingMu.Lock()
+	defer m.typingMu.Unlock()
+
+	// The indicator may have been stopped, or stopped and restarted, meanwhile
+	if m.typing[key] != state {
+		return
+	}
+	delete(m.typing, key)
+	utils.Info("Typing indicator expired: UserID=" + strconv.Itoa(key.userID) + ", ChannelID=" + strconv.Itoa(key.channelID))
+	m.broadcastTyping(models.FrameTypingStop, models.TypingPayload{ChannelID: key.channelID, UserID: key.userID})
+}
+
+// broadcastTyping sends a typing frame to the channel's subscribers except
+// the typing user's own connections. Must be called with m.typingMu held so
+// that starts and stops go out in order.
+func (m *ConnectionManager) broadcastTyping(frameType string, payload models.TypingPayload) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	eventBytes, err := models.MarshalEnvelope(frameType, "", payload)
+	if err != nil {
+		utils.Error("Failed to marshal " + frameType + " event: " + err.Error())
+		return
+	}
+	for _, client := range m.channels[payload.ChannelID] {
+		if client.UserID != payload.UserID {
+			m.enqueue(client, eventBytes)
+		}
+	}
+}
+
+// rateLimiter is a token bucket allowing bursts of up to perSecond frames.
+// It is not safe for concurrent use; each connection's read loop owns one.
+type rateLimiter struct {
+	perSecond float64
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{perSecond: float64(perSecond), tokens: float64(perSecond), last: time.Now()}
+}
+
+// Allow consumes a token, reporting false when the bucket is empty.
+func (l *rateLimiter) Allow() bool {
+	now := time.Now()
+	l.tokens = min(l.perSecond, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -2,26 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
-	"github.com/genryusaishigikuni/messenger/gateway-service/internal/authclient"
+	"errors"
 	"github.com/genryusaishigikuni/messenger/gateway-service/internal/messageclient"
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/models"
 	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
 	"github.com/gorilla/websocket"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
-type IncomingMessage struct {
-	ChannelID int    `json:"channel_id"`
-	Content   string `json:"content"`
-}
-
 var upgraded = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
-func WebSocketHandler(manager *ConnectionManager, authURL, messageURL string) http.HandlerFunc {
+func WebSocketHandler(manager *ConnectionManager, verifier *tokenverify.Verifier, messageURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Incoming WebSocket connection request")
 
@@ -33,16 +31,40 @@ func WebSocketHandler(manager *ConnectionManager, authURL, messageURL string) ht
 			return
 		}
 
-		// Validate token with auth service
-		userID, err := authclient.ValidateToken(token, authURL)
+		// Optional initial subscriptions, e.g. ?channels=1,5:120,7
+		// where 5:120 resumes channel 5 after message ID 120
+		initialChannels, err := parseChannelList(r.URL.Query().Get("channels"))
+		if err != nil {
+			utils.Error("Invalid channels query parameter: " + err.Error())
+			http.Error(w, "Invalid channels parameter", http.StatusBadRequest)
+			return
+		}
+
+		// Validate token against the auth service's keys
+		identity, err := verifier.Verify(token)
 		if err != nil {
 			utils.Error("Token validation failed: " + err.Error())
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		userID := identity.UserID
 
 		utils.Info("Token validated successfully for userID: " + strconv.Itoa(userID))
 
+		// Only subscribe to channels the user may read, e.g. their own DMs
+		for _, cursor := range initialChannels {
+			if err := messageclient.CheckChannelAccess(messageURL, token, cursor.ChannelID); err != nil {
+				utils.Error("Access check failed for ChannelID=" + strconv.Itoa(cursor.ChannelID) + ": " + err.Error())
+				status := http.StatusBadGateway
+				var statusErr *messageclient.StatusError
+				if errors.As(err, &statusErr) {
+					status = statusErr.StatusCode
+				}
+				http.Error(w, "Cannot subscribe to channel "+strconv.Itoa(cursor.ChannelID), status)
+				return
+			}
+		}
+
 		// Upgrade the connection to WebSocket
 		conn, err := upgraded.Upgrade(w, r, nil)
 		if err != nil {
@@ -52,23 +74,103 @@ func WebSocketHandler(manager *ConnectionManager, authURL, messageURL string) ht
 
 		utils.Info("WebSocket connection established")
 
-		// Assign default channel ID for now
-		channelID := 1
-		manager.RegisterClient(conn, userID, channelID, token)
-		utils.Info("Client registered: UserID=" + strconv.Itoa(userID) + ", ChannelID=" + strconv.Itoa(channelID))
+		manager.RegisterClient(conn, userID, identity.SessionID, token)
+		var replays []channelCursor
+		for _, cursor := range initialChannels {
+			if cursor.Since == nil {
+				manager.Subscribe(conn, cursor.ChannelID)
+				continue
+			}
+			manager.SubscribeWithReplay(conn, cursor.ChannelID)
+			replays = append(replays, cursor)
+		}
+		utils.Info("Client registered: UserID=" + strconv.Itoa(userID))
+
+		go func() {
+			for _, cursor := range replays {
+				if err := replayHistory(conn, manager, messageURL, token, cursor.ChannelID, *cursor.Since); err != nil {
+					sendUpstreamError(manager, conn, "", err)
+				}
+			}
+		}()
+		go handleClientMessages(conn, manager, verifier, messageURL, userID)
+	}
+}
+
+// channelCursor is a channel to subscribe to, optionally resuming after a message ID.
+type channelCursor struct {
+	ChannelID int
+	Since     *int
+}
+
+// parseChannelList parses a comma-separated list of channel IDs, each
+// optionally followed by ":<since message ID>".
+func parseChannelList(raw string) ([]channelCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var cursors []channelCursor
+	for _, part := range strings.Split(raw, ",") {
+		idPart, sincePart, hasSince := strings.Cut(strings.TrimSpace(part), ":")
+		channelID, err := strconv.Atoi(idPart)
+		if err != nil {
+			return nil, err
+		}
+		if channelID < 1 {
+			return nil, strconv.ErrRange
+		}
+		cursor := channelCursor{ChannelID: channelID}
+		if hasSince {
+			since, err := strconv.Atoi(sincePart)
+			if err != nil {
+				return nil, err
+			}
+			if since < 0 {
+				return nil, strconv.ErrRange
+			}
+			cursor.Since = &since
+		}
+		cursors = append(cursors, cursor)
+	}
+	return cursors, nil
+}
 
-		go handleClientMessages(conn, manager, messageURL, userID, channelID)
+// replayHistory streams up to ReplayLimit messages after since to the connection
+// and then releases live traffic held back by SubscribeWithReplay.
+func replayHistory(conn *websocket.Conn, manager *ConnectionManager, messageURL, token string, channelID, since int) error {
+	utils.Info("Replaying history for ChannelID=" + strconv.Itoa(channelID) + " since ID=" + strconv.Itoa(since))
+	messages, truncated, err := messageclient.GetMessages(messageURL, token, channelID, since, manager.cfg.ReplayLimit)
+	if err != nil {
+		utils.Error("Failed to fetch history for replay: " + err.Error())
+		// Release held live messages even though the gap could not be filled
+		manager.FinishReplay(conn, channelID, nil, false)
+		return err
+	}
+	if truncated {
+		utils.Info("Replay for ChannelID=" + strconv.Itoa(channelID) + " truncated at " + strconv.Itoa(len(messages)) + " messages")
 	}
+	manager.FinishReplay(conn, channelID, messages, truncated)
+	return nil
 }
 
-func handleClientMessages(conn *websocket.Conn, manager *ConnectionManager, messageURL string, userID, channelID int) {
+func handleClientMessages(conn *websocket.Conn, manager *ConnectionManager, verifier *tokenverify.Verifier, messageURL string, userID int) {
 	defer func() {
-		utils.Info("Unregistering client: UserID=" + strconv.Itoa(userID) + ", ChannelID=" + strconv.Itoa(channelID))
-		manager.UnregisterClient(conn, channelID)
+		utils.Info("Unregistering client: UserID=" + strconv.Itoa(userID))
+		manager.UnregisterClient(conn)
 	}()
 
 	// Retrieve token from manager
-	token := manager.GetTokenForClient(conn, channelID)
+	token := manager.GetTokenForClient(conn)
+	typingLimiter := newRateLimiter(manager.cfg.TypingRateLimit)
+
+	// A client that answers neither data nor pings within the pong timeout is dead
+	if err := manager.ExtendReadDeadline(conn); err != nil {
+		utils.Error("Failed to set read deadline: " + err.Error())
+		return
+	}
+	conn.SetPongHandler(func(string) error {
+		return manager.ExtendReadDeadline(conn)
+	})
 
 	for {
 		_, msgBytes, err := conn.ReadMessage()
@@ -76,25 +178,230 @@ func handleClientMessages(conn *websocket.Conn, manager *ConnectionManager, mess
 			utils.Error("Error reading WebSocket message: " + err.Error())
 			return
 		}
+		if err := manager.ExtendReadDeadline(conn); err != nil {
+			utils.Error("Failed to set read deadline: " + err.Error())
+			return
+		}
+		manager.Touch(conn)
 
-		// Parse the incoming message
-		var incMsg IncomingMessage
-		if err := json.Unmarshal(msgBytes, &incMsg); err != nil {
-			utils.Error("Invalid message format: " + err.Error())
+		// Parse the envelope
+		var env models.Envelope
+		if err := json.Unmarshal(msgBytes, &env); err != nil {
+			utils.Error("Invalid frame format: " + err.Error())
+			sendError(manager, conn, "", models.ErrCodeBadRequest, "invalid frame")
 			continue
 		}
 
-		utils.Info("Received message: ChannelID=" + strconv.Itoa(incMsg.ChannelID) + ", Content=" + incMsg.Content)
-
-		// Create and store message using the message service
-		storedMsg, err := messageclient.CreateMessage(messageURL, token, userID, incMsg.ChannelID, incMsg.Content)
-		if err != nil {
-			utils.Error("Failed to store message: " + err.Error())
+		if env.Version != models.ProtocolVersion {
+			utils.Error("Unsupported protocol version: " + strconv.Itoa(env.Version))
+			sendError(manager, conn, env.ID, models.ErrCodeUnsupportedVersion, "unsupported protocol version")
 			continue
 		}
 
-		// Broadcast the stored message to the channel
-		manager.BroadcastToChannel(incMsg.ChannelID, storedMsg)
-		utils.Info("Message broadCasted: ChannelID=" + strconv.Itoa(incMsg.ChannelID))
+		switch env.Type {
+		case models.FrameSend:
+			handleSendFrame(conn, manager, messageURL, token, userID, env)
+		case models.FrameSubscribe, models.FrameUnsubscribe:
+			handleSubscriptionFrame(conn, manager, messageURL, token, env)
+		case models.FrameHistory:
+			handleHistoryFrame(conn, manager, messageURL, token, env)
+		case models.FrameAuth:
+			if refreshed, ok := handleAuthFrame(conn, manager, verifier, userID, env); ok {
+				token = refreshed
+			}
+		case models.FrameTypingStart, models.FrameTypingStop:
+			if !typingLimiter.Allow() {
+				sendError(manager, conn, env.ID, models.ErrCodeRateLimited, "too many typing frames")
+				continue
+			}
+			handleTypingFrame(conn, manager, userID, env)
+		default:
+			utils.Error("Unknown frame type: " + env.Type)
+			sendError(manager, conn, env.ID, models.ErrCodeUnknownType, "unknown frame type")
+		}
+	}
+}
+
+func handleSendFrame(conn *websocket.Conn, manager *ConnectionManager, messageURL, token string, userID int, env models.Envelope) {
+	var payload models.SendPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil || payload.ChannelID < 1 || payload.Content == "" {
+		utils.Error("Invalid send payload")
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "channel_id and content are required")
+		return
+	}
+
+	utils.Info("Received message: ChannelID=" + strconv.Itoa(payload.ChannelID) + ", Content=" + payload.Content)
+
+	// Create and store message using the message service
+	storedMsg, err := messageclient.CreateMessage(messageURL, token, userID, payload.ChannelID, payload.Content, payload.ParentID)
+	if err != nil {
+		utils.Error("Failed to store message: " + err.Error())
+		sendUpstreamError(manager, conn, env.ID, err)
+		return
+	}
+
+	sendAck(manager, conn, env.ID, models.AckPayload{MessageID: storedMsg.ID, ChannelID: storedMsg.ChannelID})
+
+	// Posting ends the sender's typing indicator
+	manager.StopTyping(userID, payload.ChannelID)
+
+	// Broadcast the stored message to the channel
+	manager.BroadcastToChannel(payload.ChannelID, storedMsg)
+	utils.Info("Message broadCasted: ChannelID=" + strconv.Itoa(payload.ChannelID))
+}
+
+// handleAuthFrame validates a replacement token for the connection and
+// returns it once the manager holds it. The connection is bound to the new
+// token's session from then on.
+func handleAuthFrame(conn *websocket.Conn, manager *ConnectionManager, verifier *tokenverify.Verifier, userID int, env models.Envelope) (string, bool) {
+	var payload models.AuthPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil || payload.Token == "" {
+		utils.Error("Invalid auth payload")
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "token is required")
+		return "", false
+	}
+
+	identity, err := verifier.Verify(payload.Token)
+	if err != nil {
+		utils.Error("Replacement token validation failed: " + err.Error())
+		sendError(manager, conn, env.ID, models.ErrCodeUnauthorized, "invalid token")
+		return "", false
+	}
+	if identity.UserID != userID {
+		utils.Error("Replacement token belongs to UserID=" + strconv.Itoa(identity.UserID) + ", not UserID=" + strconv.Itoa(userID))
+		sendError(manager, conn, env.ID, models.ErrCodeForbidden, "token belongs to another user")
+		return "", false
+	}
+
+	manager.SetTokenForClient(conn, identity.SessionID, payload.Token)
+	sendAck(manager, conn, env.ID, models.AckPayload{})
+	return payload.Token, true
+}
+
+// handleTypingFrame relays typing_start/typing_stop to the channel. Typing
+// state lives only in the gateway and never reaches the message service.
+func handleTypingFrame(conn *websocket.Conn, manager *ConnectionManager, userID int, env models.Envelope) {
+	var payload models.TypingPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil || payload.ChannelID < 1 {
+		utils.Error("Invalid typing payload")
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "channel_id is required")
+		return
+	}
+
+	if env.Type == models.FrameTypingStop {
+		manager.StopTyping(userID, payload.ChannelID)
+		return
+	}
+	if err := manager.StartTyping(conn, payload.ChannelID); err != nil {
+		sendError(manager, conn, env.ID, models.ErrCodeForbidden, "subscribe to the channel before typing in it")
+	}
+}
+
+func handleSubscriptionFrame(conn *websocket.Conn, manager *ConnectionManager, messageURL, token string, env models.Envelope) {
+	var payload models.SubscribePayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil || payload.ChannelID < 1 {
+		utils.Error("Invalid subscription payload")
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "channel_id is required")
+		return
+	}
+
+	if env.Type == models.FrameUnsubscribe {
+		manager.Unsubscribe(conn, payload.ChannelID)
+		sendAck(manager, conn, env.ID, models.AckPayload{ChannelID: payload.ChannelID})
+		return
+	}
+
+	if err := messageclient.CheckChannelAccess(messageURL, token, payload.ChannelID); err != nil {
+		utils.Error("Access check failed for ChannelID=" + strconv.Itoa(payload.ChannelID) + ": " + err.Error())
+		sendUpstreamError(manager, conn, env.ID, err)
+		return
+	}
+
+	if payload.Since == nil {
+		manager.Subscribe(conn, payload.ChannelID)
+		sendAck(manager, conn, env.ID, models.AckPayload{ChannelID: payload.ChannelID})
+		return
+	}
+
+	if *payload.Since < 0 {
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "since must not be negative")
+		return
+	}
+	manager.SubscribeWithReplay(conn, payload.ChannelID)
+	sendAck(manager, conn, env.ID, models.AckPayload{ChannelID: payload.ChannelID})
+	if err := replayHistory(conn, manager, messageURL, token, payload.ChannelID, *payload.Since); err != nil {
+		sendUpstreamError(manager, conn, env.ID, err)
+	}
+}
+
+func handleHistoryFrame(conn *websocket.Conn, manager *ConnectionManager, messageURL, token string, env models.Envelope) {
+	var payload models.HistoryRequestPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil || payload.ChannelID < 1 {
+		utils.Error("Invalid history payload")
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "channel_id is required")
+		return
+	}
+	if payload.Order != "" && payload.Order != "asc" && payload.Order != "desc" {
+		sendError(manager, conn, env.ID, models.ErrCodeBadRequest, "order must be asc or desc")
+		return
+	}
+
+	page, err := messageclient.GetMessagePage(messageURL, token, payload.ChannelID, messageclient.HistoryQuery{
+		AfterID:        payload.Since,
+		BeforeID:       payload.Before,
+		Limit:          payload.Limit,
+		Descending:     payload.Order == "desc",
+		IncludeReplies: payload.IncludeReplies,
+	})
+	if err != nil {
+		utils.Error("Failed to fetch history: " + err.Error())
+		sendUpstreamError(manager, conn, env.ID, err)
+		return
+	}
+
+	sendFrame(manager, conn, models.FrameHistory, env.ID, models.HistoryPayload{
+		ChannelID:  payload.ChannelID,
+		Messages:   page.Messages,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	})
+}
+
+func sendFrame(manager *ConnectionManager, conn *websocket.Conn, frameType, id string, payload interface{}) {
+	data, err := models.MarshalEnvelope(frameType, id, payload)
+	if err != nil {
+		utils.Error("Failed to marshal " + frameType + " frame: " + err.Error())
+		return
+	}
+	manager.SendToClient(conn, data)
+}
+
+func sendAck(manager *ConnectionManager, conn *websocket.Conn, id string, payload models.AckPayload) {
+	sendFrame(manager, conn, models.FrameAck, id, payload)
+}
+
+func sendError(manager *ConnectionManager, conn *websocket.Conn, id, code, message string) {
+	sendFrame(manager, conn, models.FrameError, id, models.ErrorPayload{Code: code, Message: message})
+}
+
+// sendUpstreamError maps a message service failure onto a structured error frame.
+func sendUpstreamError(manager *ConnectionManager, conn *websocket.Conn, id string, err error) {
+	var statusErr *messageclient.StatusError
+	if !errors.As(err, &statusErr) {
+		sendError(manager, conn, id, models.ErrCodeUpstream, "message service unavailable")
+		return
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusBadRequest:
+		sendError(manager, conn, id, models.ErrCodeBadRequest, "request rejected by message service")
+	case http.StatusUnauthorized:
+		sendError(manager, conn, id, models.ErrCodeUnauthorized, "unauthorized")
+	case http.StatusForbidden:
+		sendError(manager, conn, id, models.ErrCodeForbidden, "forbidden")
+	case http.StatusNotFound:
+		sendError(manager, conn, id, models.ErrCodeNotFound, "not found")
+	default:
+		sendError(manager, conn, id, models.ErrCodeUpstream, statusErr.Error())
 	}
 }
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/models"
@@ -16,12 +18,19 @@ import (
 type createMessageRequest struct {
 	ChannelID int    `json:"channel_id"`
 	Content   string `json:"content"`
+	ParentID  *int   `json:"parent_id,omitempty"`
 }
 
-func CreateMessage(messageURL, token string, userID, channelID int, content string) (models.Message, error) {
-	utils.Info("Preparing to create a message")
-	_ = userID
-	// Determine the message service URL
+// StatusError is returned when the message service answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("message service returned status %d", e.StatusCode)
+}
+
+func resolveURL(messageURL string) string {
 	if messageURL == "" {
 		envURL := os.Getenv("MESSAGE_SERVICE_URL")
 		if envURL == "" {
@@ -29,12 +38,21 @@ func CreateMessage(messageURL, token string, userID, channelID int, content stri
 		}
 		messageURL = envURL
 	}
+	return messageURL
+}
+
+func CreateMessage(messageURL, token string, userID, channelID int, content string, parentID *int) (models.Message, error) {
+	utils.Info("Preparing to create a message")
+	_ = userID
+	// Determine the message service URL
+	messageURL = resolveURL(messageURL)
 	utils.Info("Message service URL: " + messageURL)
 
 	// Prepare the request data
 	reqData := createMessageRequest{
 		ChannelID: channelID,
 		Content:   content,
+		ParentID:  parentID,
 	}
 	jsonBytes, err := json.Marshal(reqData)
 	if err != nil {
@@ -71,7 +89,7 @@ func CreateMessage(messageURL, token string, userID, channelID int, content stri
 	// Check the response status code
 	if resp.StatusCode != http.StatusOK {
 		utils.Error(fmt.Sprintf("Message service returned status %d", resp.StatusCode))
-		return models.Message{}, fmt.Errorf("failed to create message: status %d", resp.StatusCode)
+		return models.Message{}, &StatusError{StatusCode: resp.StatusCode}
 	}
 	utils.Info("Message service returned a successful response")
 
@@ -85,3 +103,131 @@ func CreateMessage(messageURL, token string, userID, channelID int, content stri
 	utils.Info("Message created successfully: " + fmt.Sprintf("ID=%d, ChannelID=%d, Content=%s", msg.ID, msg.ChannelID, msg.Content))
 	return msg, nil
 }
+
+// CheckChannelAccess asks the message service whether the token's user may
+// access a channel. It returns a *StatusError with 403 or 404 when not.
+func CheckChannelAccess(messageURL, token string, channelID int) error {
+	utils.Info("Checking access to channel ID: " + strconv.Itoa(channelID))
+	messageURL = resolveURL(messageURL)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	req, err := http.NewRequest("GET", messageURL+"/api/channels/"+strconv.Itoa(channelID), nil)
+	if err != nil {
+		utils.Error("Failed to create HTTP request: " + err.Error())
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utils.Error("Failed to send request to message service: " + err.Error())
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			utils.Error("Failed to close response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		utils.Error(fmt.Sprintf("Channel access check returned status %d", resp.StatusCode))
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// HistoryQuery mirrors the pagination parameters of the message service
+// history endpoint.
+type HistoryQuery struct {
+	BeforeID       int
+	AfterID        int
+	Limit          int
+	Descending     bool
+	IncludeReplies bool
+}
+
+// replayPageSize is the page size used when walking history for a replay.
+const replayPageSize = 200
+
+// GetMessagePage fetches one page of a channel's history from the message service.
+func GetMessagePage(messageURL, token string, channelID int, q HistoryQuery) (models.MessagePage, error) {
+	utils.Info("Fetching message history page for channel ID: " + strconv.Itoa(channelID))
+	messageURL = resolveURL(messageURL)
+
+	query := url.Values{}
+	query.Set("channel", strconv.Itoa(channelID))
+	if q.AfterID > 0 {
+		query.Set("after", strconv.Itoa(q.AfterID))
+	}
+	if q.BeforeID > 0 {
+		query.Set("before", strconv.Itoa(q.BeforeID))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Descending {
+		query.Set("order", "desc")
+	}
+	if q.IncludeReplies {
+		query.Set("include_replies", "true")
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	req, err := http.NewRequest("GET", messageURL+"/api/messages/history?"+query.Encode(), nil)
+	if err != nil {
+		utils.Error("Failed to create HTTP request: " + err.Error())
+		return models.MessagePage{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utils.Error("Failed to send request to message service: " + err.Error())
+		return models.MessagePage{}, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			utils.Error("Failed to close response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		utils.Error(fmt.Sprintf("Message service returned status %d", resp.StatusCode))
+		return models.MessagePage{}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var page models.MessagePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		utils.Error("Failed to decode response body: " + err.Error())
+		return models.MessagePage{}, err
+	}
+
+	utils.Info(fmt.Sprintf("Fetched %d messages for channel ID %d", len(page.Messages), channelID))
+	return page, nil
+}
+
+// GetMessages walks the channel's history page by page and returns up to
+// limit messages with an ID greater than afterID, oldest first, and whether
+// more remain. Thread replies are included so a resumed client misses nothing.
+func GetMessages(messageURL, token string, channelID, afterID, limit int) ([]models.Message, bool, error) {
+	var messages []models.Message
+	q := HistoryQuery{AfterID: afterID, IncludeReplies: true}
+	for {
+		// One extra message tells whether the history goes on past limit
+		q.Limit = min(replayPageSize, limit+1-len(messages))
+		page, err := GetMessagePage(messageURL, token, channelID, q)
+		if err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, page.Messages...)
+		if len(messages) > limit {
+			return messages[:limit], true, nil
+		}
+		if page.NextCursor == nil {
+			return messages, false, nil
+		}
+		q.AfterID = *page.NextCursor
+	}
+}
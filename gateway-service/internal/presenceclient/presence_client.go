@@ -0,0 +1,96 @@
+package presenceclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/gateway-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
+)
+
+type joinRequest struct {
+	ChannelID int `json:"channel_id"`
+}
+
+type leaveUserRequest struct {
+	UserID int `json:"user_id"`
+}
+
+func resolveURL(presenceURL string) string {
+	if presenceURL == "" {
+		envURL := os.Getenv("PRESENCE_SERVICE_URL")
+		if envURL == "" {
+			envURL = "http://localhost:8083"
+		}
+		presenceURL = envURL
+	}
+	return presenceURL
+}
+
+// Join marks the token's user online in the presence service. A channelID of
+// 0 means the user is connected without being in a particular channel.
+func Join(presenceURL, token string, channelID int) error {
+	utils.Info("Notifying presence service of join: ChannelID=" + strconv.Itoa(channelID))
+	body, err := json.Marshal(joinRequest{ChannelID: channelID})
+	if err != nil {
+		utils.Error("Failed to marshal join request: " + err.Error())
+		return err
+	}
+	return post(resolveURL(presenceURL)+"/api/presence/join", token, body)
+}
+
+// LeaveUser marks userID offline in the presence service. It authenticates
+// with the shared internal token rather than the user's access token, which
+// may have expired by the time their last socket closes.
+func LeaveUser(presenceURL, internalToken string, userID int) error {
+	utils.Info("Notifying presence service of leave: UserID=" + strconv.Itoa(userID))
+	body, err := json.Marshal(leaveUserRequest{UserID: userID})
+	if err != nil {
+		utils.Error("Failed to marshal leave request: " + err.Error())
+		return err
+	}
+	return send(resolveURL(presenceURL)+"/api/internal/presence/leave", body, func(req *http.Request) {
+		internalauth.Sign(req, internalToken)
+	})
+}
+
+func post(url, token string, body []byte) error {
+	return send(url, body, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+}
+
+func send(url string, body []byte, authenticate func(*http.Request)) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		utils.Error("Failed to create HTTP request: " + err.Error())
+		return err
+	}
+	authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		utils.Error("Failed to send request to presence service: " + err.Error())
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			utils.Error("Failed to close response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		utils.Error(fmt.Sprintf("Presence service returned status %d", resp.StatusCode))
+		return fmt.Errorf("presence service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
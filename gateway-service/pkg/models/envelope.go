@@ -0,0 +1,149 @@
+package models
+
+import "encoding/json"
+
+// ProtocolVersion is the version of the /ws envelope protocol spoken by the gateway.
+const ProtocolVersion = 1
+
+// Frame types carried in Envelope.Type.
+const (
+	FrameSend        = "send"
+	FrameAck         = "ack"
+	FrameError       = "error"
+	FrameMessage     = "message"
+	FramePresence    = "presence"
+	FrameTypingStart = "typing_start"
+	FrameTypingStop  = "typing_stop"
+	FrameHistory     = "history"
+	FrameSubscribe   = "subscribe"
+	FrameUnsubscribe = "unsubscribe"
+	FrameAuth        = "auth"
+
+	// Frames relayed from the message service
+	FrameMessageEdited     = "message_edited"
+	FrameMessageDeleted    = "message_deleted"
+	FrameReactionAdded     = "reaction_added"
+	FrameReactionRemoved   = "reaction_removed"
+	FrameMemberAdded       = "member_added"
+	FrameMemberRemoved     = "member_removed"
+	FrameMemberRoleChanged = "member_role_changed"
+	FrameChannelUpdated    = "channel_updated"
+	FrameReadReceipt       = "read_receipt"
+	FrameMessagePinned     = "message_pinned"
+	FrameMessageUnpinned   = "message_unpinned"
+	FrameMentioned         = "mentioned" // sent only to the mentioned users
+)
+
+// Error codes carried in ErrorPayload.Code.
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeUpstream           = "upstream_error"
+	ErrCodeUnsupported        = "unsupported"
+	ErrCodeRateLimited        = "rate_limited"
+)
+
+// Envelope wraps every frame exchanged over /ws. ID is chosen by the client
+// and echoed back on the ack or error frame that answers it.
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SendPayload posts a message. ParentID makes it a reply in that message's thread.
+type SendPayload struct {
+	ChannelID int    `json:"channel_id"`
+	Content   string `json:"content"`
+	ParentID  *int   `json:"parent_id,omitempty"`
+}
+
+// AuthPayload replaces the access token the gateway uses on the
+// connection's behalf, e.g. after the client refreshed an expiring token.
+// The token must belong to the user who opened the connection.
+type AuthPayload struct {
+	Token string `json:"token"`
+}
+
+// SubscribePayload subscribes to a channel. When Since is set, every stored
+// message with a greater ID is replayed before live traffic.
+type SubscribePayload struct {
+	ChannelID int  `json:"channel_id"`
+	Since     *int `json:"since,omitempty"`
+}
+
+type AckPayload struct {
+	MessageID int `json:"message_id,omitempty"`
+	ChannelID int `json:"channel_id,omitempty"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type PresencePayload struct {
+	Event     string `json:"event"`
+	UserID    int    `json:"user_id"`
+	ChannelID int    `json:"channel_id"`
+}
+
+// TypingPayload is sent by clients with only ChannelID set. The gateway
+// relays it with the typing user and, on typing_start, how long the
+// indicator lasts unless refreshed.
+type TypingPayload struct {
+	ChannelID   int `json:"channel_id"`
+	UserID      int `json:"user_id,omitempty"`
+	ExpiresInMs int `json:"expires_in_ms,omitempty"`
+}
+
+// HistoryRequestPayload asks for one page of history. Since and Before are
+// message ID cursors; Order is "asc" (default) or "desc". Thread replies
+// are left out unless IncludeReplies is set.
+type HistoryRequestPayload struct {
+	ChannelID      int    `json:"channel_id"`
+	Since          int    `json:"since,omitempty"`
+	Before         int    `json:"before,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+	Order          string `json:"order,omitempty"`
+	IncludeReplies bool   `json:"include_replies,omitempty"`
+}
+
+// MemberPayload is carried by member_added, member_removed and
+// member_role_changed frames.
+type MemberPayload struct {
+	ChannelID int    `json:"channel_id"`
+	UserID    int    `json:"user_id"`
+	ActorID   int    `json:"actor_id"`
+	Role      string `json:"role,omitempty"`
+}
+
+// HistoryPayload is one page of history. On a replay that hit the replay
+// limit Truncated is set and NextCursor is the last replayed message; the
+// client pages on from there with after=NextCursor.
+type HistoryPayload struct {
+	ChannelID  int       `json:"channel_id"`
+	Messages   []Message `json:"messages"`
+	NextCursor *int      `json:"next_cursor,omitempty"`
+	PrevCursor *int      `json:"prev_cursor,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// MarshalEnvelope builds a serialized envelope of the given type around payload.
+func MarshalEnvelope(frameType, id string, payload interface{}) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Version: ProtocolVersion,
+		Type:    frameType,
+		ID:      id,
+		Payload: raw,
+	})
+}
@@ -6,30 +6,75 @@ import (
 )
 
 type Message struct {
-	ID        int       `json:"id"`
-	ChannelID int       `json:"channel_id"`
-	UserID    int       `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	ChannelID int        `json:"channel_id"`
+	UserID    int        `json:"user_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Threads: ParentID is set on replies; the counters are set on thread roots.
+	ParentID    *int       `json:"parent_id,omitempty"`
+	ReplyCount  int        `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
+	Mentions  []Mention         `json:"mentions,omitempty"`
+}
+
+// Mention is one mention in a message: a user ("user", with UserID set),
+// "channel" or "here".
+type Mention struct {
+	Kind   string `json:"kind"`
+	UserID int    `json:"user_id,omitempty"`
+}
+
+// ReactionSummary aggregates the reactions on a message for one emoji.
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	UserIDs []int  `json:"user_ids"`
 }
 
 // MarshalJSON is just the default, but let's just rely on the default marshaller.
 func (m Message) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		ID        int       `json:"id"`
-		ChannelID int       `json:"channel_id"`
-		UserID    int       `json:"user_id"`
-		Content   string    `json:"content"`
-		CreatedAt time.Time `json:"created_at"`
+		ID          int               `json:"id"`
+		ChannelID   int               `json:"channel_id"`
+		UserID      int               `json:"user_id"`
+		Content     string            `json:"content"`
+		CreatedAt   time.Time         `json:"created_at"`
+		EditedAt    *time.Time        `json:"edited_at,omitempty"`
+		DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+		ParentID    *int              `json:"parent_id,omitempty"`
+		ReplyCount  int               `json:"reply_count,omitempty"`
+		LastReplyAt *time.Time        `json:"last_reply_at,omitempty"`
+		Reactions   []ReactionSummary `json:"reactions,omitempty"`
+		Mentions    []Mention         `json:"mentions,omitempty"`
 	}{
-		ID:        m.ID,
-		ChannelID: m.ChannelID,
-		UserID:    m.UserID,
-		Content:   m.Content,
-		CreatedAt: m.CreatedAt,
+		ID:          m.ID,
+		ChannelID:   m.ChannelID,
+		UserID:      m.UserID,
+		Content:     m.Content,
+		CreatedAt:   m.CreatedAt,
+		EditedAt:    m.EditedAt,
+		DeletedAt:   m.DeletedAt,
+		ParentID:    m.ParentID,
+		ReplyCount:  m.ReplyCount,
+		LastReplyAt: m.LastReplyAt,
+		Reactions:   m.Reactions,
+		Mentions:    m.Mentions,
 	})
 }
 
+// MessagePage is one page of channel history as returned by the message service.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor *int      `json:"next_cursor"`
+	PrevCursor *int      `json:"prev_cursor"`
+}
+
 // Generic JSON marshaller for map[string]interface{} if needed
 
 func MarshalJSONGeneric(data map[string]interface{}) ([]byte, error) {
@@ -1,11 +1,28 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
-	AuthServiceURL    string
-	MessageServiceURL string
-	ServerPort        string
+	AuthServiceURL     string
+	MessageServiceURL  string
+	PresenceServiceURL string
+	ServerPort         string
+	SendQueueSize      int
+	SlowConsumerPolicy string
+	PingInterval       time.Duration
+	PongTimeout        time.Duration
+	MaxIdleTime        time.Duration
+	TypingTimeout      time.Duration
+	TypingRateLimit    int
+	ReplayLimit        int
+	// InternalAPIToken authenticates the other services on the gateway's
+	// internal endpoints, and the gateway on theirs. The gateway's internal
+	// endpoints refuse every call while it is unset.
+	InternalAPIToken string
 }
 
 func LoadConfig() Config {
@@ -19,14 +36,74 @@ func LoadConfig() Config {
 		msgURL = "http://localhost:8081"
 	}
 
+	presenceURL := os.Getenv("PRESENCE_SERVICE_URL")
+	if presenceURL == "" {
+		presenceURL = "http://localhost:8083"
+	}
+
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	sendQueueSize, err := strconv.Atoi(os.Getenv("SEND_QUEUE_SIZE"))
+	if err != nil || sendQueueSize < 1 {
+		sendQueueSize = 64
+	}
+
+	slowConsumerPolicy := os.Getenv("SLOW_CONSUMER_POLICY")
+	if slowConsumerPolicy == "" {
+		slowConsumerPolicy = "drop_oldest"
+	}
+
+	pingInterval := durationFromEnv("PING_INTERVAL", 30*time.Second)
+	pongTimeout := durationFromEnv("PONG_TIMEOUT", 60*time.Second)
+	maxIdleTime := durationFromEnv("MAX_IDLE_TIME", 30*time.Minute)
+	typingTimeout := durationFromEnv("TYPING_TIMEOUT", 5*time.Second)
+
+	typingRateLimit, err := strconv.Atoi(os.Getenv("TYPING_RATE_LIMIT"))
+	if err != nil || typingRateLimit < 1 {
+		typingRateLimit = 5
+	}
+
+	replayLimit, err := strconv.Atoi(os.Getenv("REPLAY_MAX_MESSAGES"))
+	if err != nil || replayLimit < 1 {
+		replayLimit = 500
+	}
+
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		Error("INTERNAL_API_TOKEN not set, internal endpoints will reject all requests")
+	}
+
 	return Config{
-		AuthServiceURL:    authURL,
-		MessageServiceURL: msgURL,
-		ServerPort:        port,
+		AuthServiceURL:     authURL,
+		MessageServiceURL:  msgURL,
+		PresenceServiceURL: presenceURL,
+		ServerPort:         port,
+		SendQueueSize:      sendQueueSize,
+		SlowConsumerPolicy: slowConsumerPolicy,
+		PingInterval:       pingInterval,
+		PongTimeout:        pongTimeout,
+		MaxIdleTime:        maxIdleTime,
+		TypingTimeout:      typingTimeout,
+		TypingRateLimit:    typingRateLimit,
+		ReplayLimit:        replayLimit,
+		InternalAPIToken:   internalToken,
+	}
+}
+
+// durationFromEnv parses a duration such as "30s" from the environment,
+// falling back to def when the variable is unset or malformed.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		Error("Invalid duration for " + key + ": " + raw)
+		return def
 	}
+	return d
 }
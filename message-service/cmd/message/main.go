@@ -2,11 +2,13 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 
 	"github.com/genryusaishigikuni/messenger/message-service/internal/handlers"
 	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
 	"github.com/gorilla/mux"
 )
 
@@ -44,6 +46,14 @@ func main() {
 	}
 	utils.Info("Database migrations completed successfully")
 
+	ownerless, err := storage.GetOwnerlessChannels(db)
+	if err != nil {
+		utils.Error("Failed to look up channels without an owner: " + err.Error())
+	}
+	for _, channel := range ownerless {
+		utils.Error(fmt.Sprintf("Channel %d (%s) has no owner; assign one with POST /api/internal/channels/%d/owner", channel.ID, channel.Name, channel.ID))
+	}
+
 	// Setup router
 	utils.Info("Setting up HTTP routes...")
 	r := mux.NewRouter()
@@ -51,16 +61,49 @@ func main() {
 	// Channels endpoints
 	r.HandleFunc("/api/channels", handlers.GetChannelsHandler(db)).Methods("GET")
 	r.HandleFunc("/api/channels", handlers.CreateChannelHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}", handlers.GetChannelHandler(db)).Methods("GET")
+	r.HandleFunc("/api/channels/{id:[0-9]+}", handlers.UpdateChannelHandler(db)).Methods("PATCH")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/archive", handlers.ArchiveChannelHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/unarchive", handlers.UnarchiveChannelHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/read", handlers.MarkChannelReadHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/reads", handlers.GetChannelReadsHandler(db)).Methods("GET")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/pins", handlers.GetPinsHandler(db)).Methods("GET")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/pins/{message_id:[0-9]+}", handlers.PinMessageHandler(db, cfg.MaxPinsPerChannel)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/pins/{message_id:[0-9]+}", handlers.UnpinMessageHandler(db)).Methods("DELETE")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/members", handlers.GetChannelMembersHandler(db)).Methods("GET")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/members", handlers.InviteMemberHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/members/{user_id:[0-9]+}", handlers.KickMemberHandler(db)).Methods("DELETE")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/members/{user_id:[0-9]+}/role", handlers.SetMemberRoleHandler(db)).Methods("PUT")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/default_role", handlers.SetDefaultRoleHandler(db)).Methods("PUT")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/join", handlers.JoinChannelHandler(db)).Methods("POST")
+	r.HandleFunc("/api/channels/{id:[0-9]+}/leave", handlers.LeaveChannelHandler(db)).Methods("POST")
+
+	// Operator endpoints, only reachable with the internal token
+	r.HandleFunc("/api/internal/channels/{id:[0-9]+}/owner", internalauth.Require(cfg.InternalAPIToken, handlers.ClaimChannelOwnerHandler(db))).Methods("POST")
+
+	// Session revocation endpoint (called by Auth Service)
+	r.HandleFunc("/api/internal/sessions/revoked", internalauth.Require(cfg.InternalAPIToken, handlers.SessionRevokedHandler())).Methods("POST")
+
+	// Direct message endpoints
+	r.HandleFunc("/api/dms", handlers.GetDirectChannelsHandler(db)).Methods("GET")
+	r.HandleFunc("/api/dms", handlers.OpenDirectChannelHandler(db)).Methods("POST")
 
 	// Messages endpoints
 	r.HandleFunc("/api/messages/history", handlers.GetMessagesHandler(db)).Methods("GET")
+	r.HandleFunc("/api/messages/search", handlers.SearchMessagesHandler(db)).Methods("GET")
 	r.HandleFunc("/api/messages", handlers.CreateMessageHandler(db)).Methods("POST")
+	r.HandleFunc("/api/messages/{id:[0-9]+}", handlers.EditMessageHandler(db)).Methods("PATCH")
+	r.HandleFunc("/api/messages/{id:[0-9]+}", handlers.DeleteMessageHandler(db)).Methods("DELETE")
+	r.HandleFunc("/api/messages/{id:[0-9]+}/edits", handlers.GetMessageEditsHandler(db)).Methods("GET")
+	r.HandleFunc("/api/messages/{id:[0-9]+}/thread", handlers.GetThreadHandler(db)).Methods("GET")
+	r.HandleFunc("/api/messages/{id:[0-9]+}/reactions", handlers.AddReactionHandler(db)).Methods("POST")
+	r.HandleFunc("/api/messages/{id:[0-9]+}/reactions", handlers.RemoveReactionHandler(db)).Methods("DELETE")
 
 	// Add CORS support
 	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 
 		if req.Method == http.MethodOptions {
 			utils.Info("CORS preflight request handled")
@@ -3,6 +3,9 @@ module github.com/genryusaishigikuni/messenger/message-service
 go 1.23.4
 
 require (
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
+	github.com/genryusaishigikuni/messenger/tokenverify v0.0.0
+	github.com/gorilla/mux v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.24
 )
+
+replace github.com/genryusaishigikuni/messenger/tokenverify => ../tokenverify
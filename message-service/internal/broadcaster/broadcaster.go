@@ -0,0 +1,86 @@
+package broadcaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
+)
+
+type ChannelEvent struct {
+	Event     string      `json:"event"`
+	ChannelID int         `json:"channel_id"`
+	Payload   interface{} `json:"payload"`
+
+	// UserIDs, when set, delivers the event to these users' connections
+	// instead of to the channel's subscribers.
+	UserIDs []int `json:"user_ids,omitempty"`
+}
+
+// BroadcastEvent asks the Gateway Service to push an event to every client
+// subscribed to channelID.
+// event is the frame type clients receive, e.g. "message_edited"
+// payload is delivered to clients as the frame payload
+func BroadcastEvent(event string, channelID int, payload interface{}) {
+	utils.Info("Preparing to broadcast " + event + " event to channel " + strconv.Itoa(channelID))
+	postEvent(ChannelEvent{Event: event, ChannelID: channelID, Payload: payload})
+}
+
+// SendUserEvent asks the Gateway Service to push an event about channelID to
+// every connection of the given users, whether or not they are subscribed
+// to the channel.
+func SendUserEvent(event string, channelID int, userIDs []int, payload interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+	utils.Info("Preparing to send " + event + " event to " + strconv.Itoa(len(userIDs)) + " users")
+	postEvent(ChannelEvent{Event: event, ChannelID: channelID, Payload: payload, UserIDs: userIDs})
+}
+
+func postEvent(ev ChannelEvent) {
+	event := ev.Event
+	gatewayURL := os.Getenv("GATEWAY_SERVICE_URL")
+	if gatewayURL == "" {
+		gatewayURL = "http://localhost:8080"
+		utils.Info("GATEWAY_SERVICE_URL not set. Using default: http://localhost:8080")
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		utils.Error("Failed to marshal channel event: " + err.Error())
+		return
+	}
+
+	req, err := http.NewRequest("POST", gatewayURL+"/api/events", bytes.NewBuffer(data))
+	if err != nil {
+		utils.Error("Failed to create channel event request: " + err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	internalauth.Sign(req, os.Getenv("INTERNAL_API_TOKEN"))
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		utils.Error("Failed to send channel event to gateway: " + err.Error())
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			utils.Error("Failed to close channel event response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		utils.Error("Gateway returned status " + http.StatusText(resp.StatusCode) + " for " + event + " event.")
+	} else {
+		utils.Info("Successfully broadcasted " + event + " event to gateway.")
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+const (
+	maxChannelTopicLength       = 250
+	maxChannelDescriptionLength = 1000
+)
+
+// PATCH /api/channels/{id} { "name": "general", "topic": "...", "description": "..." }
+// Omitted fields are left unchanged.
+type updateChannelRequest struct {
+	Name        *string `json:"name"`
+	Topic       *string `json:"topic"`
+	Description *string `json:"description"`
+}
+
+// UpdateChannelHandler PATCH /api/channels/{id}
+// Requires the rename permission.
+func UpdateChannelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to update a channel")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		var req updateChannelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Invalid update channel request: " + err.Error())
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Name == nil && req.Topic == nil && req.Description == nil {
+			http.Error(w, "nothing to update", http.StatusBadRequest)
+			return
+		}
+		if req.Name != nil {
+			if err := validateChannelName(*req.Name); err != nil {
+				utils.Error("Invalid channel name: " + err.Error())
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Topic != nil && len(*req.Topic) > maxChannelTopicLength {
+			http.Error(w, fmt.Sprintf("topic may be at most %d bytes", maxChannelTopicLength), http.StatusBadRequest)
+			return
+		}
+		if req.Description != nil && len(*req.Description) > maxChannelDescriptionLength {
+			http.Error(w, fmt.Sprintf("description may be at most %d bytes", maxChannelDescriptionLength), http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Rename)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels cannot be renamed", http.StatusBadRequest)
+			return
+		}
+
+		channel, err := storage.UpdateChannel(db, channelID, storage.ChannelUpdate{
+			Name:        req.Name,
+			Topic:       req.Topic,
+			Description: req.Description,
+		})
+		if storage.IsUniqueViolation(err) {
+			utils.Error("Channel name already taken: " + *req.Name)
+			http.Error(w, "channel name already taken", http.StatusConflict)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to update channel: %v", err))
+			http.Error(w, "could not update channel", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("Channel " + strconv.Itoa(channelID) + " updated by user " + strconv.Itoa(userID))
+		writeChannelUpdate(w, channel)
+	}
+}
+
+// ArchiveChannelHandler POST /api/channels/{id}/archive
+func ArchiveChannelHandler(db *sql.DB) http.HandlerFunc {
+	return setArchivedHandler(db, true)
+}
+
+// UnarchiveChannelHandler POST /api/channels/{id}/unarchive
+func UnarchiveChannelHandler(db *sql.DB) http.HandlerFunc {
+	return setArchivedHandler(db, false)
+}
+
+// setArchivedHandler archives or unarchives a channel. Both require the
+// archive permission; repeating either is a no-op.
+func setArchivedHandler(db *sql.DB, archive bool) http.HandlerFunc {
+	action := "unarchive"
+	if archive {
+		action = "archive"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to " + action + " a channel")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Archive)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels cannot be archived", http.StatusBadRequest)
+			return
+		}
+		if (auth.Channel.ArchivedAt != nil) == archive {
+			utils.Info("Channel " + strconv.Itoa(channelID) + " already in requested state")
+			writeChannel(w, auth.Channel)
+			return
+		}
+
+		channel, err := storage.SetChannelArchived(db, channelID, archive)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to %s channel: %v", action, err))
+			http.Error(w, "could not "+action+" channel", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("Channel " + strconv.Itoa(channelID) + " " + action + "d by user " + strconv.Itoa(userID))
+		writeChannelUpdate(w, channel)
+	}
+}
+
+// writeChannelUpdate broadcasts channel_updated and writes the channel as the response.
+func writeChannelUpdate(w http.ResponseWriter, channel *models.Channel) {
+	go broadcaster.BroadcastEvent("channel_updated", channel.ID, channel)
+	writeChannel(w, channel)
+}
+
+func writeChannel(w http.ResponseWriter, channel *models.Channel) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(channel); err != nil {
+		utils.Error("Failed to encode channel response: " + err.Error())
+		return
+	}
+	utils.Info("Channel response sent successfully")
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// POST /api/internal/channels/{id}/owner { "user_id": X }
+type claimOwnerRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// ClaimChannelOwnerHandler POST /api/internal/channels/{id}/owner
+// Operator endpoint, behind the internal token, that gives an ownerless
+// channel its first owner. Channels from before member roles that nobody
+// had posted in are left without one by the migrations; the service logs
+// them at startup. Channels that already have an owner are refused with 409.
+func ClaimChannelOwnerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to assign a channel owner")
+		channelID, err := channelIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid channel ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req claimOwnerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID < 1 {
+			utils.Error("Invalid owner request")
+			http.Error(w, "user_id is required", http.StatusBadRequest)
+			return
+		}
+
+		channel, err := storage.GetChannelByID(db, channelID)
+		if errors.Is(err, storage.ErrChannelNotFound) {
+			http.Error(w, "channel not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load channel: %v", err))
+			http.Error(w, "could not load channel", http.StatusInternalServerError)
+			return
+		}
+		if channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels have no roles", http.StatusBadRequest)
+			return
+		}
+
+		err = storage.ClaimChannelOwnership(db, channelID, req.UserID)
+		if errors.Is(err, storage.ErrChannelHasOwner) {
+			utils.Error("Channel " + strconv.Itoa(channelID) + " already has an owner")
+			http.Error(w, "channel already has an owner", http.StatusConflict)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to assign channel owner: %v", err))
+			http.Error(w, "could not assign owner", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("User " + strconv.Itoa(req.UserID) + " is now owner of channel " + strconv.Itoa(channelID))
+		event := models.MemberEvent{ChannelID: channelID, UserID: req.UserID, Role: permissions.RoleOwner}
+		go broadcaster.BroadcastEvent("member_role_changed", channelID, event)
+		writeMemberEvent(w, event)
+	}
+}
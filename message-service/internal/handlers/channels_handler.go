@@ -3,27 +3,56 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
 	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/gorilla/mux"
 )
 
 type createChannelRequest struct {
-	Name string `json:"name"`
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
 }
 
+// GetChannelsHandler GET /api/channels?include_archived=true&include_unread=true
+// include_unread adds the caller's read position, unread count and mention count
+// to each channel and requires a token.
 func GetChannelsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Received request to get channels")
+		// Anonymous callers only see non-private channels
+		userID, err := optionalUserID(r)
+		if err != nil {
+			utils.Error("Unauthorized request: " + err.Error())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 
-		channels, err := storage.GetChannels(db)
+		includeArchived := r.URL.Query().Get("include_archived") == "true"
+		channels, err := storage.GetChannels(db, userID, includeArchived)
 		if err != nil {
 			utils.Error("Failed to retrieve channels: " + err.Error())
 			http.Error(w, "could not retrieve channels", http.StatusInternalServerError)
 			return
 		}
 
+		if r.URL.Query().Get("include_unread") == "true" {
+			if userID == 0 {
+				http.Error(w, "include_unread requires a token", http.StatusUnauthorized)
+				return
+			}
+			if err := storage.AttachUnreadCounts(db, userID, channels); err != nil {
+				utils.Error("Failed to count unread messages: " + err.Error())
+				http.Error(w, "could not retrieve channels", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		utils.Info("Channels retrieved successfully")
 		w.Header().Set("Content-Type", "application/json")
 		err = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -40,6 +69,12 @@ func GetChannelsHandler(db *sql.DB) http.HandlerFunc {
 func CreateChannelHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Received request to create a channel")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error("Unauthorized request: " + err.Error())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 
 		var req createChannelRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -48,15 +83,19 @@ func CreateChannelHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		if req.Name == "" {
-			utils.Error("Channel name is missing in the request")
-			http.Error(w, "channel name required", http.StatusBadRequest)
+		if err := validateChannelName(req.Name); err != nil {
+			utils.Error("Invalid channel name: " + err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		utils.Info("Creating channel with name: " + req.Name)
-		channel, err := storage.CreateChannel(db, req.Name)
-		if err != nil {
+		channel, err := storage.CreateChannel(db, req.Name, req.Private, userID)
+		if storage.IsUniqueViolation(err) {
+			utils.Error("Channel name already taken: " + req.Name)
+			http.Error(w, "channel name already taken", http.StatusConflict)
+			return
+		} else if err != nil {
 			utils.Error("Failed to create channel: " + err.Error())
 			http.Error(w, "could not create channel", http.StatusInternalServerError)
 			return
@@ -73,3 +112,57 @@ func CreateChannelHandler(db *sql.DB) http.HandlerFunc {
 		utils.Info("Create channel response sent successfully")
 	}
 }
+
+func validateChannelName(name string) error {
+	if name == "" {
+		return errors.New("channel name required")
+	}
+	// Direct message channels are named "dm:<a>:<b>" internally
+	if strings.HasPrefix(name, "dm:") {
+		return errors.New("channel names may not start with dm:")
+	}
+	return nil
+}
+
+// channelIDFromPath reads the {id} route variable of channel routes.
+func channelIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid channel id")
+	}
+	return id, nil
+}
+
+// GetChannelHandler GET /api/channels/{id}
+// Returns the channel if the caller may access it, so other services can
+// check access before acting on a user's behalf.
+func GetChannelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get a channel")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error("Unauthorized request: " + err.Error())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		channelID, err := channelIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid channel ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Read)
+		if auth == nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(auth.Channel); err != nil {
+			utils.Error("Failed to encode channel response: " + err.Error())
+			return
+		}
+		utils.Info("Channel response sent successfully")
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// POST /api/dms { "user_id": X }
+type openDirectChannelRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// OpenDirectChannelHandler returns the caller's direct message channel with
+// another user, creating it on first use. It answers 201 when the channel
+// was created and 200 when it already existed.
+func OpenDirectChannelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to open a direct message channel")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error("Unauthorized request: " + err.Error())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req openDirectChannelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Invalid open direct channel request: " + err.Error())
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.UserID < 1 {
+			utils.Error("Direct message peer is missing in the request")
+			http.Error(w, "user_id is required", http.StatusBadRequest)
+			return
+		}
+		if req.UserID == userID {
+			utils.Error("User " + strconv.Itoa(userID) + " tried to open a direct channel with themselves")
+			http.Error(w, "cannot open a direct channel with yourself", http.StatusBadRequest)
+			return
+		}
+
+		token, _ := bearerToken(r)
+		exists, err := userExistsWithAuthService(token, req.UserID)
+		if err != nil {
+			utils.Error("Failed to look up direct message peer: " + err.Error())
+			http.Error(w, "could not look up user", http.StatusBadGateway)
+			return
+		}
+		if !exists {
+			utils.Error("Direct message peer does not exist: " + strconv.Itoa(req.UserID))
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		channel, created, err := storage.GetOrCreateDirectChannel(db, userID, req.UserID)
+		if errors.Is(err, storage.ErrDirectChannelNameTaken) {
+			utils.Error("Direct channel name is taken by a regular channel: " + err.Error())
+			http.Error(w, "direct channel name is taken by another channel", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			utils.Error("Failed to open direct channel: " + err.Error())
+			http.Error(w, "could not open direct channel", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("Direct channel " + strconv.Itoa(channel.ID) + " opened for users " +
+			strconv.Itoa(userID) + " and " + strconv.Itoa(req.UserID))
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		if err := json.NewEncoder(w).Encode(channel); err != nil {
+			utils.Error("Failed to encode direct channel response: " + err.Error())
+			return
+		}
+	}
+}
+
+// GetDirectChannelsHandler GET /api/dms?include_unread=true lists the
+// caller's direct message channels.
+func GetDirectChannelsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get direct message channels")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error("Unauthorized request: " + err.Error())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		channels, err := storage.GetDirectChannels(db, userID)
+		if err != nil {
+			utils.Error("Failed to retrieve direct channels: " + err.Error())
+			http.Error(w, "could not retrieve direct channels", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("include_unread") == "true" {
+			if err := storage.AttachUnreadCounts(db, userID, channels); err != nil {
+				utils.Error("Failed to count unread messages: " + err.Error())
+				http.Error(w, "could not retrieve direct channels", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"channels": channels,
+		}); err != nil {
+			utils.Error("Failed to encode direct channels response: " + err.Error())
+			return
+		}
+		utils.Info("Direct channels response sent successfully")
+	}
+}
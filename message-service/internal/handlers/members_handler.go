@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// POST /api/channels/{id}/members { "user_id": X }
+type inviteMemberRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// PUT /api/channels/{id}/members/{user_id}/role { "role": "moderator" }
+// PUT /api/channels/{id}/default_role { "role": "read_only" }
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// GetChannelMembersHandler GET /api/channels/{id}/members
+func GetChannelMembersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get channel members")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+		if authorizeChannel(w, db, channelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		members, err := storage.GetChannelMembers(db, channelID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to retrieve channel members: %v", err))
+			http.Error(w, "could not retrieve channel members", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"members": members,
+		}); err != nil {
+			utils.Error("Failed to encode channel members response")
+			return
+		}
+		utils.Info("Channel members retrieved successfully")
+	}
+}
+
+// JoinChannelHandler POST /api/channels/{id}/join
+// Only non-private channels can be joined without an invite. New members
+// get the channel's default role.
+func JoinChannelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to join a channel")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Read)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels cannot be joined", http.StatusBadRequest)
+			return
+		}
+		if auth.Channel.ArchivedAt != nil {
+			http.Error(w, "channel is archived", http.StatusForbidden)
+			return
+		}
+
+		addMember(w, db, channelID, userID, userID, auth.Channel.DefaultRole)
+	}
+}
+
+// InviteMemberHandler POST /api/channels/{id}/members
+// Requires the invite permission; the invitee gets the channel's default role.
+func InviteMemberHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to invite a channel member")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		var req inviteMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID < 1 {
+			utils.Error("Invalid invite request")
+			http.Error(w, "user_id is required", http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Invite)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels cannot have other members", http.StatusBadRequest)
+			return
+		}
+
+		addMember(w, db, channelID, req.UserID, userID, auth.Channel.DefaultRole)
+	}
+}
+
+// LeaveChannelHandler POST /api/channels/{id}/leave
+// The last owner of a channel must hand over ownership before leaving.
+func LeaveChannelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to leave a channel")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Read)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels cannot be left", http.StatusBadRequest)
+			return
+		}
+
+		removeMember(w, db, channelID, userID, userID)
+	}
+}
+
+// KickMemberHandler DELETE /api/channels/{id}/members/{user_id}
+// Requires the kick permission and a role above the target's; owners may
+// also remove other owners, but never the last one.
+func KickMemberHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to kick a channel member")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		targetID, err := targetUserIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid user ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.Kick)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "members cannot be removed from direct message channels", http.StatusBadRequest)
+			return
+		}
+
+		targetRole, ok := loadTargetRole(w, db, channelID, targetID)
+		if !ok {
+			return
+		}
+		if !permissions.CanManage(auth.Role, targetRole) {
+			utils.Error("User " + strconv.Itoa(userID) + " does not outrank user " + strconv.Itoa(targetID))
+			http.Error(w, "you can only remove members with a lower role than yours", http.StatusForbidden)
+			return
+		}
+
+		removeMember(w, db, channelID, targetID, userID)
+	}
+}
+
+// SetMemberRoleHandler PUT /api/channels/{id}/members/{user_id}/role
+// Requires the manage_roles permission. Callers cannot change their own
+// role or that of a member ranked at or above them, except that owners may
+// demote other owners as long as one remains.
+func SetMemberRoleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to change a member's role")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		targetID, err := targetUserIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid user ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req roleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !permissions.ValidRole(req.Role) {
+			utils.Error("Invalid role request")
+			http.Error(w, "role must be owner, moderator, member or read_only", http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.ManageRoles)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels have no roles", http.StatusBadRequest)
+			return
+		}
+		if targetID == userID {
+			http.Error(w, "you cannot change your own role", http.StatusBadRequest)
+			return
+		}
+
+		targetRole, ok := loadTargetRole(w, db, channelID, targetID)
+		if !ok {
+			return
+		}
+		if !permissions.CanManage(auth.Role, targetRole) {
+			utils.Error("User " + strconv.Itoa(userID) + " does not outrank user " + strconv.Itoa(targetID))
+			http.Error(w, "you can only change the role of members ranked below you", http.StatusForbidden)
+			return
+		}
+
+		_, err = storage.SetMemberRole(db, channelID, targetID, req.Role)
+		if errors.Is(err, storage.ErrLastOwner) {
+			utils.Error("Refused to demote the last owner of channel " + strconv.Itoa(channelID))
+			http.Error(w, "the channel's last owner cannot be demoted; make another member owner first", http.StatusConflict)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to set member role: %v", err))
+			http.Error(w, "could not change role", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("User " + strconv.Itoa(targetID) + " is now " + req.Role + " in channel " + strconv.Itoa(channelID))
+		event := models.MemberEvent{ChannelID: channelID, UserID: targetID, ActorID: userID, Role: req.Role}
+		go broadcaster.BroadcastEvent("member_role_changed", channelID, event)
+		writeMemberEvent(w, event)
+	}
+}
+
+// SetDefaultRoleHandler PUT /api/channels/{id}/default_role
+// Setting read_only turns the channel into an announcement channel where
+// only members promoted by an owner can post.
+func SetDefaultRoleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to change a channel's default role")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		var req roleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil ||
+			(req.Role != permissions.RoleMember && req.Role != permissions.RoleReadOnly) {
+			utils.Error("Invalid default role request")
+			http.Error(w, "default role must be member or read_only", http.StatusBadRequest)
+			return
+		}
+
+		auth := authorizeChannel(w, db, channelID, userID, permissions.ManageRoles)
+		if auth == nil {
+			return
+		}
+		if auth.Channel.Kind == models.ChannelKindDirect {
+			http.Error(w, "direct message channels have no roles", http.StatusBadRequest)
+			return
+		}
+
+		if err := storage.SetChannelDefaultRole(db, channelID, req.Role); err != nil {
+			utils.Error(fmt.Sprintf("Failed to set default role: %v", err))
+			http.Error(w, "could not change default role", http.StatusInternalServerError)
+			return
+		}
+
+		utils.Info("Default role of channel " + strconv.Itoa(channelID) + " set to " + req.Role)
+		auth.Channel.DefaultRole = req.Role
+		writeChannelUpdate(w, auth.Channel)
+	}
+}
+
+// memberRequestContext authenticates the caller and reads the channel ID of
+// a membership route. On failure it writes the error response.
+func memberRequestContext(w http.ResponseWriter, r *http.Request) (userID, channelID int, ok bool) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	channelID, err = channelIDFromPath(r)
+	if err != nil {
+		utils.Error("Invalid channel ID")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, channelID, true
+}
+
+// targetUserIDFromPath reads the {user_id} route variable.
+func targetUserIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid user id")
+	}
+	return id, nil
+}
+
+// loadTargetRole returns the role of the member an action targets, writing
+// a 404 when they are not a member.
+func loadTargetRole(w http.ResponseWriter, db *sql.DB, channelID, targetID int) (string, bool) {
+	role, err := storage.GetMemberRole(db, channelID, targetID)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Failed to load member role: %v", err))
+		http.Error(w, "could not load member", http.StatusInternalServerError)
+		return "", false
+	}
+	if role == "" {
+		utils.Error("User " + strconv.Itoa(targetID) + " is not a member of channel " + strconv.Itoa(channelID))
+		http.Error(w, "user is not a member of this channel", http.StatusNotFound)
+		return "", false
+	}
+	return role, true
+}
+
+func addMember(w http.ResponseWriter, db *sql.DB, channelID, userID, actorID int, role string) {
+	added, err := storage.AddChannelMember(db, channelID, userID, role)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Failed to add channel member: %v", err))
+		http.Error(w, "could not add member", http.StatusInternalServerError)
+		return
+	}
+
+	event := models.MemberEvent{ChannelID: channelID, UserID: userID, ActorID: actorID, Role: role}
+	if added {
+		utils.Info("User " + strconv.Itoa(userID) + " added to channel " + strconv.Itoa(channelID))
+		go broadcaster.BroadcastEvent("member_added", channelID, event)
+	} else if event.Role, err = storage.GetMemberRole(db, channelID, userID); err != nil {
+		utils.Error(fmt.Sprintf("Failed to load member role: %v", err))
+		http.Error(w, "could not add member", http.StatusInternalServerError)
+		return
+	}
+	writeMemberEvent(w, event)
+}
+
+// removeMember removes userID from the channel. The member_removed event
+// also tells the gateway to drop that user's subscriptions to the channel.
+func removeMember(w http.ResponseWriter, db *sql.DB, channelID, userID, actorID int) {
+	removed, err := storage.RemoveChannelMember(db, channelID, userID)
+	if errors.Is(err, storage.ErrLastOwner) {
+		utils.Error("Refused to remove the last owner of channel " + strconv.Itoa(channelID))
+		http.Error(w, "the channel's last owner cannot be removed; make another member owner first", http.StatusConflict)
+		return
+	} else if err != nil {
+		utils.Error(fmt.Sprintf("Failed to remove channel member: %v", err))
+		http.Error(w, "could not remove member", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		utils.Error("User " + strconv.Itoa(userID) + " is not a member of channel " + strconv.Itoa(channelID))
+		http.Error(w, "user is not a member of this channel", http.StatusNotFound)
+		return
+	}
+
+	utils.Info("User " + strconv.Itoa(userID) + " removed from channel " + strconv.Itoa(channelID))
+	event := models.MemberEvent{ChannelID: channelID, UserID: userID, ActorID: actorID}
+	go broadcaster.BroadcastEvent("member_removed", channelID, event)
+	writeMemberEvent(w, event)
+}
+
+func writeMemberEvent(w http.ResponseWriter, event models.MemberEvent) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(event); err != nil {
+		utils.Error("Failed to encode membership response")
+		return
+	}
+	utils.Info("Membership response sent successfully")
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/mentions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// storeMentions parses the mentions in msg's content, stores them and
+// notifies the users that were not mentioned by the message before. The
+// message is posted either way; mentions that cannot be resolved, and
+// @channel or @here from authors without the mention_all permission, are
+// dropped.
+func storeMentions(db *sql.DB, token string, auth *channelAuth, msg *models.Message) {
+	channel := auth.Channel
+	resolved := resolveMentions(db, token, channel, auth.Role, msg.Content)
+	added, err := storage.SetMessageMentions(db, msg.ID, resolved)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Failed to store mentions of message %d: %v", msg.ID, err))
+		return
+	}
+	msg.Mentions = resolved
+	notifyMentions(db, channel, *msg, added)
+}
+
+// resolveMentions turns the parsed mentions into stored form. Usernames are
+// resolved through the auth service; users who cannot read a private or
+// direct message channel are left out so that mentioning them reveals nothing.
+// @channel and @here are kept only when the author's role may notify everyone.
+func resolveMentions(db *sql.DB, token string, channel *models.Channel, role, content string) []models.Mention {
+	parsed := mentions.Parse(content)
+	var resolved []models.Mention
+	// Both members of a direct message channel are members, so anyone may
+	// notify the other there
+	mentionAll := channel.Kind == models.ChannelKindDirect || permissions.Has(role, permissions.MentionAll)
+	if (parsed.Channel || parsed.Here) && !mentionAll {
+		utils.Info("Dropping @channel/@here from a member without the mention_all permission in channel " + strconv.Itoa(channel.ID))
+	}
+	if parsed.Channel && mentionAll {
+		resolved = append(resolved, models.Mention{Kind: models.MentionChannel})
+	}
+	if parsed.Here && mentionAll {
+		resolved = append(resolved, models.Mention{Kind: models.MentionHere})
+	}
+	if len(parsed.Usernames) == 0 {
+		return resolved
+	}
+
+	userIDs, err := lookupUsersWithAuthService(token, parsed.Usernames)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Failed to resolve mentioned usernames: %v", err))
+		return resolved
+	}
+
+	restricted := channel.Private || channel.Kind == models.ChannelKindDirect
+	for _, username := range parsed.Usernames {
+		userID, ok := userIDs[username]
+		if !ok {
+			continue
+		}
+		if restricted {
+			role, err := storage.GetMemberRole(db, channel.ID, userID)
+			if err != nil {
+				utils.Error(fmt.Sprintf("Failed to load channel role: %v", err))
+				continue
+			}
+			if role == "" {
+				continue
+			}
+		}
+		resolved = append(resolved, models.Mention{Kind: models.MentionUser, UserID: userID})
+	}
+	return resolved
+}
+
+// notifyMentions sends a mentioned event to every user the mentions reach,
+// except the author. @channel and @here reach every member of the channel;
+// the gateway only delivers to connected users, which is all @here asks for.
+func notifyMentions(db *sql.DB, channel *models.Channel, msg models.Message, added []models.Mention) {
+	kinds := make(map[int]string)
+	for _, m := range added {
+		if m.Kind == models.MentionUser {
+			kinds[m.UserID] = models.MentionUser
+			continue
+		}
+		members, err := storage.GetChannelMembers(db, channel.ID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load channel members: %v", err))
+			continue
+		}
+		for _, member := range members {
+			if _, ok := kinds[member.UserID]; !ok || kinds[member.UserID] == models.MentionHere {
+				kinds[member.UserID] = m.Kind
+			}
+		}
+	}
+	delete(kinds, msg.UserID)
+
+	recipients := make(map[string][]int)
+	for userID, kind := range kinds {
+		recipients[kind] = append(recipients[kind], userID)
+	}
+	for kind, userIDs := range recipients {
+		utils.Info("Notifying " + strconv.Itoa(len(userIDs)) + " users of " + kind + " mention in message " + strconv.Itoa(msg.ID))
+		go broadcaster.SendUserEvent("mentioned", msg.ChannelID, userIDs, models.MentionEvent{Kind: kind, Message: msg})
+	}
+}
+
+type authLookupResponse struct {
+	Users []struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"users"`
+}
+
+// lookupUsersWithAuthService resolves usernames to user IDs through Auth
+// Service's /api/auth/users endpoint. Unknown usernames are absent from the
+// result.
+func lookupUsersWithAuthService(token string, usernames []string) (map[string]int, error) {
+	utils.Info("Looking up " + strconv.Itoa(len(usernames)) + " usernames with Auth Service")
+	lookup, err := queryAuthUsers(token, url.Values{"username": usernames})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make(map[string]int, len(lookup.Users))
+	for _, u := range lookup.Users {
+		userIDs[u.Username] = u.ID
+	}
+	return userIDs, nil
+}
+
+// userExistsWithAuthService reports whether Auth Service knows the user ID.
+func userExistsWithAuthService(token string, userID int) (bool, error) {
+	utils.Info("Checking with Auth Service that user " + strconv.Itoa(userID) + " exists")
+	lookup, err := queryAuthUsers(token, url.Values{"id": {strconv.Itoa(userID)}})
+	if err != nil {
+		return false, err
+	}
+	for _, u := range lookup.Users {
+		if u.ID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// queryAuthUsers calls Auth Service's /api/auth/users with the given query.
+func queryAuthUsers(token string, query url.Values) (*authLookupResponse, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	req, err := http.NewRequest("GET", authServiceURL()+"/api/auth/users?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call auth service: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		if chError := Body.Close(); chError != nil {
+			utils.Error("Failed to close response body")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user lookup failed with status %d", resp.StatusCode)
+	}
+
+	var lookup authLookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&lookup); err != nil {
+		return nil, fmt.Errorf("failed to parse auth service response: %w", err)
+	}
+	return &lookup, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// DeleteMessageHandler DELETE /api/messages/{id}
+// Authors need the post permission in the channel; deleting someone else's
+// message needs delete_others.
+// The message is replaced by a tombstone rather than removed.
+func DeleteMessageHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to delete a message")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		messageID, err := messageIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid message ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		existing, err := storage.GetMessageByID(db, messageID)
+		if errors.Is(err, storage.ErrMessageNotFound) {
+			utils.Error("Message not found: " + strconv.Itoa(messageID))
+			http.Error(w, "message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+			http.Error(w, "could not delete message", http.StatusInternalServerError)
+			return
+		}
+
+		perm := permissions.Post
+		if existing.UserID != userID {
+			perm = permissions.DeleteOthers
+		}
+		if authorizeChannel(w, db, existing.ChannelID, userID, perm) == nil {
+			return
+		}
+		if perm == permissions.DeleteOthers {
+			utils.Info("Moderator " + strconv.Itoa(userID) + " is deleting message " + strconv.Itoa(messageID))
+		}
+
+		msg, err := storage.DeleteMessage(db, messageID, userID)
+		if errors.Is(err, storage.ErrMessageDeleted) {
+			utils.Error("Message already deleted: " + strconv.Itoa(messageID))
+			http.Error(w, "message has been deleted", http.StatusGone)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to delete message: %v", err))
+			http.Error(w, "could not delete message", http.StatusInternalServerError)
+			return
+		}
+
+		go broadcaster.BroadcastEvent("message_deleted", msg.ChannelID, msg)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(msg); err != nil {
+			utils.Error("Failed to encode delete message response")
+			return
+		}
+		utils.Info("Message deleted successfully")
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// PATCH /api/messages/{id} { "content": "Hello again" }
+type editMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// messageIDFromPath reads the {id} route variable.
+func messageIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid message id")
+	}
+	return id, nil
+}
+
+// EditMessageHandler changes the content of a message. Authors need the post
+// permission in the channel; editing someone else's message needs edit_others.
+func EditMessageHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to edit a message")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		messageID, err := messageIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid message ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req editMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Invalid request body")
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Content == "" {
+			utils.Error("Content is missing")
+			http.Error(w, "content is required", http.StatusBadRequest)
+			return
+		}
+
+		existing, err := storage.GetMessageByID(db, messageID)
+		if errors.Is(err, storage.ErrMessageNotFound) {
+			utils.Error("Message not found: " + strconv.Itoa(messageID))
+			http.Error(w, "message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+			http.Error(w, "could not edit message", http.StatusInternalServerError)
+			return
+		}
+
+		perm := permissions.Post
+		if existing.UserID != userID {
+			perm = permissions.EditOthers
+		}
+		auth := authorizeChannel(w, db, existing.ChannelID, userID, perm)
+		if auth == nil {
+			return
+		}
+
+		msg, err := storage.EditMessage(db, messageID, req.Content)
+		if errors.Is(err, storage.ErrMessageDeleted) {
+			utils.Error("Cannot edit deleted message: " + strconv.Itoa(messageID))
+			http.Error(w, "message has been deleted", http.StatusGone)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to edit message: %v", err))
+			http.Error(w, "could not edit message", http.StatusInternalServerError)
+			return
+		}
+
+		// The token was validated above, so the header is well-formed
+		token, _ := bearerToken(r)
+		storeMentions(db, token, auth, msg)
+
+		go broadcaster.BroadcastEvent("message_edited", msg.ChannelID, msg)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(msg); err != nil {
+			utils.Error("Failed to encode edit message response")
+			return
+		}
+		utils.Info("Message edited successfully")
+	}
+}
+
+// GetMessageEditsHandler GET /api/messages/{id}/edits
+func GetMessageEditsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get message edits")
+		messageID, err := messageIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid message ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		msg, err := storage.GetMessageByID(db, messageID)
+		if errors.Is(err, storage.ErrMessageNotFound) {
+			http.Error(w, "message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+			http.Error(w, "could not retrieve message edits", http.StatusInternalServerError)
+			return
+		}
+
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if authorizeChannel(w, db, msg.ChannelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		edits, err := storage.GetMessageEdits(db, messageID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to retrieve message edits: %v", err))
+			http.Error(w, "could not retrieve message edits", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"edits": edits,
+		}); err != nil {
+			utils.Error("Failed to encode message edits response")
+			return
+		}
+		utils.Info("Message edits retrieved successfully")
+	}
+}
@@ -5,22 +5,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
 	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
 )
 
-// GetMessagesHandler GET /api/messages/history?channel=<id>
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// GetMessagesHandler GET /api/messages/history?channel=<id>&before=<message_id>&after=<message_id>&limit=<n>&order=asc|desc&include_replies=true
+// Thread replies are omitted unless include_replies is set. Private and
+// direct message channels are readable only by their members.
 func GetMessagesHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Received request to get messages")
-		channelIDStr := r.URL.Query().Get("channel")
+		query := r.URL.Query()
+		channelIDStr := query.Get("channel")
 		if channelIDStr == "" {
 			utils.Error("Channel query parameter is missing")
 			http.Error(w, "channel query param required", http.StatusBadRequest)
@@ -34,7 +43,25 @@ func GetMessagesHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		messages, err := storage.GetMessagesByChannel(db, channelID)
+		historyQuery, err := parseHistoryQuery(query)
+		if err != nil {
+			utils.Error("Invalid history query: " + err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if authorizeChannel(w, db, channelID, userID, permissions.Read) == nil {
+			return
+		}
+		historyQuery.IncludeReplies = query.Get("include_replies") == "true"
+
+		page, err := storage.GetMessagesByChannel(db, channelID, historyQuery)
 		if err != nil {
 			utils.Error(fmt.Sprintf("Failed to retrieve messages: %v", err))
 			http.Error(w, "could not retrieve messages", http.StatusInternalServerError)
@@ -42,7 +69,7 @@ func GetMessagesHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(messages); err != nil {
+		if err := json.NewEncoder(w).Encode(page); err != nil {
 			utils.Error("Failed to encode messages response")
 			return
 		}
@@ -50,10 +77,48 @@ func GetMessagesHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// POST /api/messages { "channel_id": X, "content": "Hello" }
+// parseHistoryQuery reads the pagination parameters of a history request.
+// The limit is clamped to maxHistoryLimit.
+func parseHistoryQuery(query url.Values) (storage.HistoryQuery, error) {
+	q := storage.HistoryQuery{Limit: defaultHistoryLimit}
+
+	cursors := map[string]*int{"before": &q.BeforeID, "after": &q.AfterID}
+	for name, target := range cursors {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return q, fmt.Errorf("invalid %s cursor", name)
+		}
+		*target = value
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return q, errors.New("invalid limit")
+		}
+		q.Limit = min(limit, maxHistoryLimit)
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	return q, nil
+}
+
+// POST /api/messages { "channel_id": X, "content": "Hello", "parent_id": Y }
 type createMessageRequest struct {
 	ChannelID int    `json:"channel_id"`
 	Content   string `json:"content"`
+	ParentID  *int   `json:"parent_id,omitempty"`
 }
 
 func CreateMessageHandler(db *sql.DB) http.HandlerFunc {
@@ -79,13 +144,42 @@ func CreateMessageHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		msg, err := storage.CreateMessage(db, req.ChannelID, userID, req.Content)
+		auth := authorizeChannel(w, db, req.ChannelID, userID, permissions.Post)
+		if auth == nil {
+			return
+		}
+
+		if req.ParentID != nil {
+			parent, err := storage.GetMessageByID(db, *req.ParentID)
+			if errors.Is(err, storage.ErrMessageNotFound) {
+				utils.Error("Parent message not found: " + strconv.Itoa(*req.ParentID))
+				http.Error(w, "parent message not found", http.StatusBadRequest)
+				return
+			} else if err != nil {
+				utils.Error(fmt.Sprintf("Failed to load parent message: %v", err))
+				http.Error(w, "could not create message", http.StatusInternalServerError)
+				return
+			}
+
+			// Threads are one level deep and never cross channels
+			if parent.ChannelID != req.ChannelID || parent.ParentID != nil || parent.DeletedAt != nil {
+				utils.Error("Invalid parent message: " + strconv.Itoa(*req.ParentID))
+				http.Error(w, "parent must be a live top-level message in the same channel", http.StatusBadRequest)
+				return
+			}
+		}
+
+		msg, err := storage.CreateMessage(db, req.ChannelID, userID, req.Content, req.ParentID)
 		if err != nil {
 			utils.Error(fmt.Sprintf("Failed to create message: %v", err))
 			http.Error(w, "could not create message", http.StatusInternalServerError)
 			return
 		}
 
+		// The token was validated above, so the header is well-formed
+		token, _ := bearerToken(r)
+		storeMentions(db, token, auth, msg)
+
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(msg); err != nil {
 			utils.Error("Failed to encode create message response")
@@ -95,84 +189,55 @@ func CreateMessageHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Placeholder function: In a real-world scenario, validate the JWT by calling Auth Service
-type authValidateResponse struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Valid    bool   `json:"valid"`
-}
-
-// extractUserIDFromToken now calls the Auth Service to validate the JWT.
-func extractUserIDFromToken(r *http.Request) (int, error) {
-	utils.Info("Extracting user ID from token")
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		utils.Error("No authorization header provided")
-		return 0, errors.New("no authorization header provided")
-	}
-
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		utils.Error("Invalid authorization header format")
-		return 0, errors.New("invalid authorization header format")
-	}
-	token := parts[1]
+// tokenVerifier checks bearer tokens against the Auth Service's published
+// keys, asking the Auth Service itself only when those are unavailable.
+var tokenVerifier = tokenverify.New(authServiceURL())
 
-	userID, err := validateTokenWithAuthService(token)
-	if err != nil {
-		utils.Error(fmt.Sprintf("Token validation failed: %v", err))
-		return 0, err
-	}
-
-	utils.Info("Token validated successfully")
-	return userID, nil
+// SessionRevokedHandler POST /api/internal/sessions/revoked
+// Called by the Auth Service when it signs a session out, so the session's
+// access tokens stop working here before they expire.
+func SessionRevokedHandler() http.HandlerFunc {
+	return tokenVerifier.RevocationHandler(nil)
 }
 
-// validateTokenWithAuthService makes a GET request to Auth Service's /api/auth/validate endpoint
-// with the bearer token and parses the response.
-func validateTokenWithAuthService(token string) (int, error) {
-	utils.Info("Validating token with Auth Service")
+func authServiceURL() string {
 	authURL := os.Getenv("AUTH_SERVICE_URL")
 	if authURL == "" {
 		authURL = "http://localhost:8082"
 	}
+	return authURL
+}
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", authURL+"/api/auth/validate", nil)
+// extractUserIDFromToken returns the user of the request's bearer token.
+func extractUserIDFromToken(r *http.Request) (int, error) {
+	utils.Info("Extracting user ID from token")
+	token, err := bearerToken(r)
 	if err != nil {
-		utils.Error(fmt.Sprintf("Failed to create request: %v", err))
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return 0, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := client.Do(req)
+	claims, err := tokenVerifier.Verify(token)
 	if err != nil {
-		utils.Error(fmt.Sprintf("Failed to call auth service: %v", err))
-		return 0, fmt.Errorf("failed to call auth service: %w", err)
+		utils.Error(fmt.Sprintf("Token validation failed: %v", err))
+		return 0, err
 	}
-	defer func(Body io.ReadCloser) {
-		if chError := Body.Close(); chError != nil {
-			utils.Error("Failed to close response body")
-		}
-	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		utils.Error(fmt.Sprintf("Token validation failed with status %d", resp.StatusCode))
-		return 0, fmt.Errorf("token validation failed with status %d", resp.StatusCode)
-	}
+	utils.Info("Token validated successfully")
+	return claims.UserID, nil
+}
 
-	var validateResp authValidateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&validateResp); err != nil {
-		utils.Error(fmt.Sprintf("Failed to parse auth service response: %v", err))
-		return 0, fmt.Errorf("failed to parse auth service response: %w", err)
+// bearerToken returns the raw token of the request's Authorization header.
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		utils.Error("No authorization header provided")
+		return "", errors.New("no authorization header provided")
 	}
 
-	if !validateResp.Valid {
-		utils.Error("Invalid token according to auth service")
-		return 0, errors.New("invalid token according to auth service")
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		utils.Error("Invalid authorization header format")
+		return "", errors.New("invalid authorization header format")
 	}
-
-	utils.Info("Token validated successfully by Auth Service")
-	return validateResp.UserID, nil
+	return parts[1], nil
 }
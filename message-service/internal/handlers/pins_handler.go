@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// pinnedMessageIDFromPath reads the {message_id} route variable.
+func pinnedMessageIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["message_id"])
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid message id")
+	}
+	return id, nil
+}
+
+// GetPinsHandler GET /api/channels/{id}/pins
+// Lists the channel's pinned messages, most recently pinned first.
+func GetPinsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to list pinned messages")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+		if authorizeChannel(w, db, channelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		pins, err := storage.GetPins(db, channelID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to list pins: %v", err))
+			http.Error(w, "could not retrieve pins", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"pins": pins,
+		}); err != nil {
+			utils.Error("Failed to encode pins response")
+			return
+		}
+		utils.Info("Pinned messages listed successfully")
+	}
+}
+
+// PinMessageHandler POST /api/channels/{id}/pins/{message_id}
+// Needs the pin permission. A channel holds at most maxPins pins; pinning
+// an already pinned message returns the existing pin without an event.
+func PinMessageHandler(db *sql.DB, maxPins int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to pin a message")
+		userID, channelID, msg, ok := pinRequestContext(w, r, db)
+		if !ok {
+			return
+		}
+		if msg.DeletedAt != nil {
+			utils.Error("Cannot pin deleted message: " + strconv.Itoa(msg.ID))
+			http.Error(w, "message has been deleted", http.StatusGone)
+			return
+		}
+
+		pin, created, err := storage.PinMessage(db, channelID, msg.ID, userID, maxPins)
+		if errors.Is(err, storage.ErrPinLimitReached) {
+			utils.Error("Pin limit reached in channel " + strconv.Itoa(channelID))
+			http.Error(w, "channel already has "+strconv.Itoa(maxPins)+" pinned messages", http.StatusConflict)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to pin message: %v", err))
+			http.Error(w, "could not pin message", http.StatusInternalServerError)
+			return
+		}
+		pin.Message = msg
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			go broadcaster.BroadcastEvent("message_pinned", channelID, pin)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(pin); err != nil {
+			utils.Error("Failed to encode pin response")
+			return
+		}
+		utils.Info("Message pinned successfully")
+	}
+}
+
+// UnpinMessageHandler DELETE /api/channels/{id}/pins/{message_id}
+func UnpinMessageHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to unpin a message")
+		userID, channelID, msg, ok := pinRequestContext(w, r, db)
+		if !ok {
+			return
+		}
+
+		removed, err := storage.UnpinMessage(db, channelID, msg.ID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to unpin message: %v", err))
+			http.Error(w, "could not unpin message", http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			utils.Error("Message is not pinned: " + strconv.Itoa(msg.ID))
+			http.Error(w, "message is not pinned", http.StatusNotFound)
+			return
+		}
+
+		go broadcaster.BroadcastEvent("message_unpinned", channelID, models.UnpinEvent{
+			ChannelID: channelID,
+			MessageID: msg.ID,
+			ActorID:   userID,
+		})
+
+		w.WriteHeader(http.StatusNoContent)
+		utils.Info("Message unpinned successfully")
+	}
+}
+
+// pinRequestContext authenticates a pin or unpin request, checks the pin
+// permission and loads the message, which must belong to the channel.
+func pinRequestContext(w http.ResponseWriter, r *http.Request, db *sql.DB) (userID, channelID int, msg *models.Message, ok bool) {
+	userID, channelID, ok = memberRequestContext(w, r)
+	if !ok {
+		return 0, 0, nil, false
+	}
+	messageID, err := pinnedMessageIDFromPath(r)
+	if err != nil {
+		utils.Error("Invalid message ID")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, 0, nil, false
+	}
+
+	if authorizeChannel(w, db, channelID, userID, permissions.Pin) == nil {
+		return 0, 0, nil, false
+	}
+
+	msg, err = storage.GetMessageByID(db, messageID)
+	if errors.Is(err, storage.ErrMessageNotFound) || (err == nil && msg.ChannelID != channelID) {
+		utils.Error("Message " + strconv.Itoa(messageID) + " is not in channel " + strconv.Itoa(channelID))
+		http.Error(w, "message not found", http.StatusNotFound)
+		return 0, 0, nil, false
+	} else if err != nil {
+		utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+		http.Error(w, "could not load message", http.StatusInternalServerError)
+		return 0, 0, nil, false
+	}
+	return userID, channelID, msg, true
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// maxEmojiLength bounds the emoji string in bytes; multi-codepoint emoji
+// such as flags and skin-tone sequences fit comfortably.
+const maxEmojiLength = 64
+
+// POST/DELETE /api/messages/{id}/reactions { "emoji": "👍" }
+type reactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// AddReactionHandler POST /api/messages/{id}/reactions
+func AddReactionHandler(db *sql.DB) http.HandlerFunc {
+	return reactionHandler(db, true)
+}
+
+// RemoveReactionHandler DELETE /api/messages/{id}/reactions
+func RemoveReactionHandler(db *sql.DB) http.HandlerFunc {
+	return reactionHandler(db, false)
+}
+
+// reactionHandler adds or removes the caller's reaction. Repeating an add or
+// remove is a no-op and does not broadcast an event.
+func reactionHandler(db *sql.DB, add bool) http.HandlerFunc {
+	action, event := "remove", "reaction_removed"
+	if add {
+		action, event = "add", "reaction_added"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to " + action + " a reaction")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		messageID, err := messageIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid message ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req reactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Invalid request body")
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if !validEmoji(req.Emoji) {
+			utils.Error("Invalid emoji: " + req.Emoji)
+			http.Error(w, "emoji is required and may not contain whitespace", http.StatusBadRequest)
+			return
+		}
+
+		msg, err := storage.GetMessageByID(db, messageID)
+		if errors.Is(err, storage.ErrMessageNotFound) {
+			utils.Error("Message not found: " + strconv.Itoa(messageID))
+			http.Error(w, "message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+			http.Error(w, "could not "+action+" reaction", http.StatusInternalServerError)
+			return
+		}
+		if authorizeChannel(w, db, msg.ChannelID, userID, permissions.Post) == nil {
+			return
+		}
+		if msg.DeletedAt != nil {
+			utils.Error("Cannot react to deleted message: " + strconv.Itoa(messageID))
+			http.Error(w, "message has been deleted", http.StatusGone)
+			return
+		}
+
+		var changed bool
+		if add {
+			changed, err = storage.AddReaction(db, messageID, userID, req.Emoji)
+		} else {
+			changed, err = storage.RemoveReaction(db, messageID, userID, req.Emoji)
+		}
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to %s reaction: %v", action, err))
+			http.Error(w, "could not "+action+" reaction", http.StatusInternalServerError)
+			return
+		}
+
+		count, err := storage.CountReactions(db, messageID, req.Emoji)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to count reactions: %v", err))
+			http.Error(w, "could not "+action+" reaction", http.StatusInternalServerError)
+			return
+		}
+
+		reaction := models.ReactionEvent{
+			MessageID: messageID,
+			ChannelID: msg.ChannelID,
+			UserID:    userID,
+			Emoji:     req.Emoji,
+			Count:     count,
+		}
+		if changed {
+			go broadcaster.BroadcastEvent(event, msg.ChannelID, reaction)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reaction); err != nil {
+			utils.Error("Failed to encode reaction response")
+			return
+		}
+		utils.Info("Reaction request handled successfully")
+	}
+}
+
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiLength {
+		return false
+	}
+	return !strings.ContainsFunc(emoji, unicode.IsSpace)
+}
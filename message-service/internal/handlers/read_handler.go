@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/broadcaster"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// POST /api/channels/{id}/read { "message_id": X }
+// Without message_id the channel is read up to its newest message.
+type markReadRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+// MarkChannelReadHandler POST /api/channels/{id}/read
+// Read positions only move forward. When the position moves, a
+// read_receipt event is broadcast so other participants and the caller's
+// other devices can update.
+func MarkChannelReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to mark a channel read")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+
+		var req markReadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			utils.Error("Invalid mark read request")
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.MessageID < 0 {
+			http.Error(w, "invalid message_id", http.StatusBadRequest)
+			return
+		}
+
+		if authorizeChannel(w, db, channelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		messageID := req.MessageID
+		if messageID == 0 {
+			latest, err := storage.GetLatestMessageID(db, channelID)
+			if err != nil {
+				utils.Error(fmt.Sprintf("Failed to load latest message: %v", err))
+				http.Error(w, "could not mark channel read", http.StatusInternalServerError)
+				return
+			}
+			messageID = latest
+		} else {
+			msg, err := storage.GetMessageByID(db, messageID)
+			if errors.Is(err, storage.ErrMessageNotFound) || (err == nil && msg.ChannelID != channelID) {
+				utils.Error("Message " + strconv.Itoa(messageID) + " is not in channel " + strconv.Itoa(channelID))
+				http.Error(w, "message is not in this channel", http.StatusBadRequest)
+				return
+			} else if err != nil {
+				utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+				http.Error(w, "could not mark channel read", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		receipt, moved, err := storage.MarkChannelRead(db, channelID, userID, messageID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to mark channel read: %v", err))
+			http.Error(w, "could not mark channel read", http.StatusInternalServerError)
+			return
+		}
+		if moved {
+			go broadcaster.BroadcastEvent("read_receipt", channelID, receipt)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(receipt); err != nil {
+			utils.Error("Failed to encode read receipt response")
+			return
+		}
+		utils.Info("User " + strconv.Itoa(userID) + " read channel " + strconv.Itoa(channelID) + " up to " + strconv.Itoa(receipt.LastReadMessageID))
+	}
+}
+
+// GetChannelReadsHandler GET /api/channels/{id}/reads lists how far each
+// user has read, e.g. to show "seen" in a direct message.
+func GetChannelReadsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get channel read receipts")
+		userID, channelID, ok := memberRequestContext(w, r)
+		if !ok {
+			return
+		}
+		if authorizeChannel(w, db, channelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		receipts, err := storage.GetChannelReads(db, channelID)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to retrieve read receipts: %v", err))
+			http.Error(w, "could not retrieve read receipts", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"reads": receipts,
+		}); err != nil {
+			utils.Error("Failed to encode read receipts response")
+			return
+		}
+		utils.Info("Read receipts retrieved successfully")
+	}
+}
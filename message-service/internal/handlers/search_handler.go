@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchMessagesHandler GET /api/messages/search?q=<text>&channel=<id>&author=<user_id>&from=<time>&to=<time>&before=<message_id>&limit=<n>
+// Results are newest first and limited to channels the caller can read.
+// from and to accept RFC 3339 timestamps or YYYY-MM-DD dates. Snippets are
+// HTML-escaped message text with matching terms wrapped in <mark> tags.
+func SearchMessagesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to search messages")
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		q, err := parseSearchQuery(r.URL.Query())
+		if err != nil {
+			utils.Error("Invalid search query: " + err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.UserID = userID
+
+		if q.ChannelID > 0 && authorizeChannel(w, db, q.ChannelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		page, err := storage.SearchMessages(db, q)
+		if errors.Is(err, storage.ErrEmptySearch) {
+			http.Error(w, "q must contain at least one word", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to search messages: %v", err))
+			http.Error(w, "could not search messages", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			utils.Error("Failed to encode search response")
+			return
+		}
+		utils.Info(fmt.Sprintf("Search returned %d results", len(page.Results)))
+	}
+}
+
+// parseSearchQuery reads the filters and pagination of a search request.
+func parseSearchQuery(query url.Values) (storage.SearchQuery, error) {
+	q := storage.SearchQuery{Text: query.Get("q"), Limit: defaultSearchLimit}
+	if q.Text == "" {
+		return q, errors.New("q is required")
+	}
+
+	ids := map[string]*int{"channel": &q.ChannelID, "author": &q.AuthorID, "before": &q.BeforeID}
+	for name, target := range ids {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return q, fmt.Errorf("invalid %s", name)
+		}
+		*target = value
+	}
+
+	times := map[string]*time.Time{"from": &q.From, "to": &q.To}
+	for name, target := range times {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, raw); err != nil {
+				return q, fmt.Errorf("invalid %s; use RFC 3339 or YYYY-MM-DD", name)
+			}
+		}
+		*target = t
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return q, errors.New("invalid limit")
+		}
+		q.Limit = min(limit, maxSearchLimit)
+	}
+	return q, nil
+}
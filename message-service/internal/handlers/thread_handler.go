@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/internal/storage"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
+)
+
+// GetThreadHandler GET /api/messages/{id}/thread?before=<message_id>&after=<message_id>&limit=<n>&order=asc|desc
+// returns the thread root together with one page of its replies.
+func GetThreadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Received request to get a thread")
+		messageID, err := messageIDFromPath(r)
+		if err != nil {
+			utils.Error("Invalid message ID")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		historyQuery, err := parseHistoryQuery(r.URL.Query())
+		if err != nil {
+			utils.Error("Invalid thread query: " + err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		parent, err := storage.GetMessageByID(db, messageID)
+		if errors.Is(err, storage.ErrMessageNotFound) {
+			utils.Error("Message not found: " + strconv.Itoa(messageID))
+			http.Error(w, "message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			utils.Error(fmt.Sprintf("Failed to load message: %v", err))
+			http.Error(w, "could not retrieve thread", http.StatusInternalServerError)
+			return
+		}
+
+		userID, err := extractUserIDFromToken(r)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Unauthorized request: %v", err))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if authorizeChannel(w, db, parent.ChannelID, userID, permissions.Read) == nil {
+			return
+		}
+
+		if parent.ParentID != nil {
+			utils.Error("Message " + strconv.Itoa(messageID) + " is a reply, not a thread root")
+			http.Error(w, "message is a reply; request its parent thread instead", http.StatusBadRequest)
+			return
+		}
+
+		replies, err := storage.GetThreadReplies(db, messageID, historyQuery)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Failed to retrieve thread replies: %v", err))
+			http.Error(w, "could not retrieve thread", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(models.Thread{Parent: *parent, Replies: *replies}); err != nil {
+			utils.Error("Failed to encode thread response")
+			return
+		}
+		utils.Info("Thread retrieved successfully")
+	}
+}
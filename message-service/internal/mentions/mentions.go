@@ -0,0 +1,43 @@
+package mentions
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxUsernames bounds how many distinct users one message may mention;
+// further usernames are ignored.
+const MaxUsernames = 50
+
+// Parsed holds the mentions found in a message's content.
+type Parsed struct {
+	Usernames []string // distinct, in order of first appearance
+	Channel   bool     // @channel
+	Here      bool     // @here
+}
+
+// A mention is an @ at the start of the content or after a character that
+// cannot be part of a word or address, so "bob@example.com" mentions nobody.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w[\w.\-]*)`)
+
+// Parse finds the @username, @channel and @here mentions in content.
+// Trailing dots and dashes are treated as punctuation, not part of the name.
+func Parse(content string) Parsed {
+	var p Parsed
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		switch name {
+		case "channel":
+			p.Channel = true
+		case "here":
+			p.Here = true
+		default:
+			if !seen[name] && len(p.Usernames) < MaxUsernames {
+				seen[name] = true
+				p.Usernames = append(p.Usernames, name)
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,76 @@
+package permissions
+
+// Roles a channel member can hold, from most to least privileged.
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+	RoleReadOnly  = "read_only"
+)
+
+// Permission is an action a member may take in a channel.
+type Permission string
+
+const (
+	Read         Permission = "read"
+	Post         Permission = "post" // send messages, react, edit and delete one's own messages
+	EditOthers   Permission = "edit_others"
+	DeleteOthers Permission = "delete_others"
+	Pin          Permission = "pin"
+	Invite       Permission = "invite"
+	Kick         Permission = "kick"
+	Rename       Permission = "rename"
+	Archive      Permission = "archive"
+	ManageRoles  Permission = "manage_roles"
+	// MentionAll lets @channel and @here notify every member.
+	MentionAll Permission = "mention_all"
+)
+
+// minimumRole is the least privileged role that holds each permission.
+var minimumRole = map[Permission]string{
+	Read:         RoleReadOnly,
+	Post:         RoleMember,
+	Invite:       RoleMember,
+	EditOthers:   RoleModerator,
+	DeleteOthers: RoleModerator,
+	Pin:          RoleModerator,
+	MentionAll:   RoleModerator,
+	Kick:         RoleModerator,
+	Rename:       RoleModerator,
+	Archive:      RoleOwner,
+	ManageRoles:  RoleOwner,
+}
+
+var rank = map[string]int{
+	RoleReadOnly:  1,
+	RoleMember:    2,
+	RoleModerator: 3,
+	RoleOwner:     4,
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// Has reports whether role grants the permission. The empty role of a
+// non-member grants nothing.
+func Has(role string, p Permission) bool {
+	required, ok := minimumRole[p]
+	if !ok {
+		return false
+	}
+	return rank[role] >= rank[required]
+}
+
+// CanManage reports whether a member with role a may kick a member with
+// role b or change b's role. That takes a strictly higher role, except that
+// owners may act on other owners so a channel can hand over ownership.
+// Callers must still keep the channel from losing its last owner.
+func CanManage(a, b string) bool {
+	if a == RoleOwner {
+		return true
+	}
+	return rank[a] > rank[b]
+}
@@ -2,14 +2,50 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/mattn/go-sqlite3"
 )
 
-func CreateChannel(db *sql.DB, name string) (*models.Channel, error) {
-	res, err := db.Exec("INSERT INTO channels (name) VALUES (?)", name)
+var ErrChannelNotFound = errors.New("channel not found")
+
+// ErrDirectChannelNameTaken is returned when a regular channel, e.g. one
+// created before "dm:" names were reserved, holds the name of a user pair's
+// direct channel.
+var ErrDirectChannelNameTaken = errors.New("direct channel name is taken")
+
+const channelColumns = "id, name, topic, description, kind, private, default_role, dm_user_a, dm_user_b, created_at, archived_at"
+
+func scanChannel(row rowScanner) (models.Channel, error) {
+	var c models.Channel
+	var userA, userB sql.NullInt64
+	var archivedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.Name, &c.Topic, &c.Description, &c.Kind, &c.Private, &c.DefaultRole, &userA, &userB, &c.CreatedAt, &archivedAt)
+	if userA.Valid && userB.Valid {
+		c.Participants = []int{int(userA.Int64), int(userB.Int64)}
+	}
+	if archivedAt.Valid {
+		c.ArchivedAt = &archivedAt.Time
+	}
+	return c, err
+}
+
+// CreateChannel inserts a channel and makes its creator the owner.
+func CreateChannel(db *sql.DB, name string, private bool, createdBy int) (*models.Channel, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	res, err := tx.Exec("INSERT INTO channels (name, private) VALUES (?, ?)", name, private)
 	if err != nil {
 		return nil, err
 	}
@@ -17,16 +53,44 @@ func CreateChannel(db *sql.DB, name string) (*models.Channel, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := tx.Exec("INSERT INTO channel_members (channel_id, user_id, role) VALUES (?, ?, ?)", id, createdBy, permissions.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return &models.Channel{
-		ID:        int(id),
-		Name:      name,
-		CreatedAt: time.Now(),
+		ID:          int(id),
+		Name:        name,
+		Kind:        models.ChannelKindPublic,
+		Private:     private,
+		DefaultRole: permissions.RoleMember,
+		CreatedAt:   time.Now(),
 	}, nil
 }
 
-func GetChannels(db *sql.DB) ([]models.Channel, error) {
-	rows, err := db.Query("SELECT id, name, created_at FROM channels ORDER BY id ASC")
+// GetChannels lists the non-private channels and the private channels
+// userID is a member of. Archived channels are left out unless
+// includeArchived is set. Direct message channels are listed per user by
+// GetDirectChannels.
+func GetChannels(db *sql.DB, userID int, includeArchived bool) ([]models.Channel, error) {
+	query := "SELECT " + channelColumns + " FROM channels WHERE kind != ? AND (private = 0 OR id IN " +
+		"(SELECT channel_id FROM channel_members WHERE user_id = ?))"
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	return queryChannels(db, query+" ORDER BY id ASC", models.ChannelKindDirect, userID)
+}
+
+// GetDirectChannels lists the direct message channels userID takes part in.
+func GetDirectChannels(db *sql.DB, userID int) ([]models.Channel, error) {
+	return queryChannels(db, "SELECT "+channelColumns+" FROM channels WHERE kind = ? AND (dm_user_a = ? OR dm_user_b = ?) ORDER BY id ASC",
+		models.ChannelKindDirect, userID, userID)
+}
+
+func queryChannels(db *sql.DB, query string, args ...interface{}) ([]models.Channel, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -39,12 +103,113 @@ func GetChannels(db *sql.DB) ([]models.Channel, error) {
 
 	var channels []models.Channel
 	for rows.Next() {
-		var c models.Channel
-		err := rows.Scan(&c.ID, &c.Name, &c.CreatedAt)
+		c, err := scanChannel(rows)
 		if err != nil {
 			return nil, err
 		}
 		channels = append(channels, c)
 	}
-	return channels, nil
+	return channels, rows.Err()
+}
+
+func GetChannelByID(db *sql.DB, id int) (*models.Channel, error) {
+	c, err := scanChannel(db.QueryRow("SELECT "+channelColumns+" FROM channels WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChannelNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetOrCreateDirectChannel returns the direct message channel between two
+// users, creating it on first use. The same pair always maps to the same
+// channel regardless of argument order; created reports whether it is new.
+func GetOrCreateDirectChannel(db *sql.DB, userA, userB int) (channel *models.Channel, created bool, err error) {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// The generated name keeps the UNIQUE constraint on channels.name satisfied
+	name := fmt.Sprintf("dm:%d:%d", userA, userB)
+	res, err := tx.Exec("INSERT OR IGNORE INTO channels (name, kind, dm_user_a, dm_user_b) VALUES (?, ?, ?, ?)",
+		name, models.ChannelKindDirect, userA, userB)
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	c, err := scanChannel(tx.QueryRow("SELECT "+channelColumns+" FROM channels WHERE kind = ? AND dm_user_a = ? AND dm_user_b = ?",
+		models.ChannelKindDirect, userA, userB))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing was inserted and no direct channel exists, so the insert
+		// was ignored because of the name
+		return nil, false, fmt.Errorf("%w: %s", ErrDirectChannelNameTaken, name)
+	} else if err != nil {
+		return nil, false, err
+	}
+	if n > 0 {
+		for _, userID := range []int{userA, userB} {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO channel_members (channel_id, user_id) VALUES (?, ?)", c.ID, userID); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return &c, n > 0, nil
+}
+
+// SetChannelDefaultRole changes the role given to users who join the channel.
+func SetChannelDefaultRole(db *sql.DB, channelID int, role string) error {
+	_, err := db.Exec("UPDATE channels SET default_role = ? WHERE id = ?", role, channelID)
+	return err
+}
+
+// ChannelUpdate holds the channel fields to change; nil fields are kept.
+type ChannelUpdate struct {
+	Name        *string
+	Topic       *string
+	Description *string
+}
+
+// UpdateChannel applies the update and returns the updated channel.
+func UpdateChannel(db *sql.DB, channelID int, u ChannelUpdate) (*models.Channel, error) {
+	_, err := db.Exec("UPDATE channels SET name = COALESCE(?, name), topic = COALESCE(?, topic), description = COALESCE(?, description) WHERE id = ?",
+		u.Name, u.Topic, u.Description, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return GetChannelByID(db, channelID)
+}
+
+// SetChannelArchived archives or unarchives the channel and returns it.
+func SetChannelArchived(db *sql.DB, channelID int, archived bool) (*models.Channel, error) {
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now().UTC()
+	}
+	if _, err := db.Exec("UPDATE channels SET archived_at = ? WHERE id = ?", archivedAt, channelID); err != nil {
+		return nil, err
+	}
+	return GetChannelByID(db, channelID)
+}
+
+// IsUniqueViolation reports whether err is a UNIQUE constraint failure,
+// e.g. a channel name that is already taken.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
 }
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// ErrLastOwner is returned when a change would leave a channel without an
+// owner.
+var ErrLastOwner = errors.New("channel would have no owner left")
+
+// notLastOwner is appended to updates of a member row so they only apply
+// when the member is not an owner or the channel has another one. Being part
+// of the same statement, the check cannot race with a concurrent change.
+const notLastOwner = ` AND (role != 'owner' OR (SELECT COUNT(*) FROM channel_members o WHERE o.channel_id = channel_members.channel_id AND o.role = 'owner') > 1)`
+
+// ErrChannelHasOwner is returned when ownership of a channel that already
+// has an owner is claimed.
+var ErrChannelHasOwner = errors.New("channel already has an owner")
+
+// GetOwnerlessChannels lists the channels, other than direct message
+// channels, that have no owner, e.g. because they predate member roles.
+func GetOwnerlessChannels(db *sql.DB) ([]models.Channel, error) {
+	return queryChannels(db, "SELECT "+channelColumns+" FROM channels WHERE kind != ? AND NOT EXISTS "+
+		"(SELECT 1 FROM channel_members WHERE channel_id = channels.id AND role = ?) ORDER BY id ASC",
+		models.ChannelKindDirect, permissions.RoleOwner)
+}
+
+// ClaimChannelOwnership makes userID the owner of a channel that has none,
+// adding them as a member if needed. It returns ErrChannelHasOwner when the
+// channel already has an owner.
+func ClaimChannelOwnership(db *sql.DB, channelID, userID int) error {
+	res, err := db.Exec(`INSERT INTO channel_members (channel_id, user_id, role)
+		SELECT ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM channel_members WHERE channel_id = ? AND role = ?)
+		ON CONFLICT (channel_id, user_id) DO UPDATE SET role = excluded.role`,
+		channelID, userID, permissions.RoleOwner, channelID, permissions.RoleOwner)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChannelHasOwner
+	}
+	return nil
+}
+
+// AddChannelMember adds userID to the channel with the given role. It
+// reports false, leaving the existing role untouched, when the user was
+// already a member.
+func AddChannelMember(db *sql.DB, channelID, userID int, role string) (bool, error) {
+	res, err := db.Exec("INSERT OR IGNORE INTO channel_members (channel_id, user_id, role) VALUES (?, ?, ?)", channelID, userID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveChannelMember removes userID from the channel. It reports false
+// when the user was not a member, and returns ErrLastOwner instead of
+// removing the channel's only owner.
+func RemoveChannelMember(db *sql.DB, channelID, userID int) (bool, error) {
+	res, err := db.Exec("DELETE FROM channel_members WHERE channel_id = ? AND user_id = ?"+notLastOwner, channelID, userID)
+	if err != nil {
+		return false, err
+	}
+	return memberChanged(db, res, channelID, userID)
+}
+
+// GetMemberRole returns userID's role in the channel, or an empty string
+// when the user is not a member.
+func GetMemberRole(db *sql.DB, channelID, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM channel_members WHERE channel_id = ? AND user_id = ?", channelID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// SetMemberRole changes the role of an existing member. It reports false
+// when the user is not a member, and returns ErrLastOwner instead of
+// demoting the channel's only owner.
+func SetMemberRole(db *sql.DB, channelID, userID int, role string) (bool, error) {
+	query := "UPDATE channel_members SET role = ? WHERE channel_id = ? AND user_id = ?"
+	if role != permissions.RoleOwner {
+		query += notLastOwner
+	}
+	res, err := db.Exec(query, role, channelID, userID)
+	if err != nil {
+		return false, err
+	}
+	return memberChanged(db, res, channelID, userID)
+}
+
+// memberChanged interprets the result of a guarded member update: no rows
+// affected means either that the user is not a member or that they are the
+// last owner.
+func memberChanged(db *sql.DB, res sql.Result, channelID, userID int) (bool, error) {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+	role, err := GetMemberRole(db, channelID, userID)
+	if err != nil {
+		return false, err
+	}
+	if role == permissions.RoleOwner {
+		return false, ErrLastOwner
+	}
+	return false, nil
+}
+
+// GetChannelMembers lists the members of a channel in the order they joined.
+func GetChannelMembers(db *sql.DB, channelID int) ([]models.ChannelMember, error) {
+	rows, err := db.Query("SELECT channel_id, user_id, role, joined_at FROM channel_members WHERE channel_id = ? ORDER BY joined_at ASC, user_id ASC", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	members := []models.ChannelMember{}
+	for rows.Next() {
+		var m models.ChannelMember
+		if err := rows.Scan(&m.ChannelID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
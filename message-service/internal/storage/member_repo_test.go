@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+)
+
+func TestLastOwnerIsKept(t *testing.T) {
+	db := openTestDB(t)
+	channel, err := CreateChannel(db, "general", false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddChannelMember(db, channel.ID, 2, permissions.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+
+	// With two owners either one may be demoted
+	if changed, err := SetMemberRole(db, channel.ID, 1, permissions.RoleMember); err != nil || !changed {
+		t.Fatalf("demoting one of two owners = %v, %v; want true, nil", changed, err)
+	}
+	if _, err := SetMemberRole(db, channel.ID, 2, permissions.RoleModerator); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("demoting the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if _, err := RemoveChannelMember(db, channel.ID, 2); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("removing the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if changed, err := SetMemberRole(db, channel.ID, 2, permissions.RoleOwner); err != nil || !changed {
+		t.Fatalf("keeping the last owner an owner = %v, %v; want true, nil", changed, err)
+	}
+	if removed, err := RemoveChannelMember(db, channel.ID, 3); err != nil || removed {
+		t.Fatalf("removing a non-member = %v, %v; want false, nil", removed, err)
+	}
+	if role, err := GetMemberRole(db, channel.ID, 2); err != nil || role != permissions.RoleOwner {
+		t.Fatalf("last owner's role = %q, %v; want owner", role, err)
+	}
+}
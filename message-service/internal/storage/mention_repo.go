@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// SetMessageMentions replaces the stored mentions of a message and returns
+// those that were not stored before, so that an edit only notifies users
+// it newly mentions.
+func SetMessageMentions(db *sql.DB, messageID int, mentions []models.Mention) ([]models.Mention, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	rows, err := tx.Query("SELECT kind, user_id FROM message_mentions WHERE message_id = ?", messageID)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[models.Mention]bool)
+	for rows.Next() {
+		var m models.Mention
+		if err := rows.Scan(&m.Kind, &m.UserID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		existing[m] = true
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM message_mentions WHERE message_id = ?", messageID); err != nil {
+		return nil, err
+	}
+	var added []models.Mention
+	for _, m := range mentions {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO message_mentions (message_id, kind, user_id) VALUES (?, ?, ?)", messageID, m.Kind, m.UserID); err != nil {
+			return nil, err
+		}
+		if !existing[m] {
+			added = append(added, m)
+		}
+	}
+	return added, tx.Commit()
+}
+
+// attachMentions fills in the mentions of every message in the slice,
+// channel-wide mentions first.
+func attachMentions(db *sql.DB, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Message, len(messages))
+	placeholders := make([]string, 0, len(messages))
+	args := make([]interface{}, 0, len(messages))
+	for i := range messages {
+		index[messages[i].ID] = &messages[i]
+		placeholders = append(placeholders, "?")
+		args = append(args, messages[i].ID)
+	}
+
+	rows, err := db.Query("SELECT message_id, kind, user_id FROM message_mentions WHERE message_id IN ("+
+		strings.Join(placeholders, ", ")+") ORDER BY message_id, user_id, kind", args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var messageID int
+		var m models.Mention
+		if err := rows.Scan(&messageID, &m.Kind, &m.UserID); err != nil {
+			return err
+		}
+		index[messageID].Mentions = append(index[messageID].Mentions, m)
+	}
+	return rows.Err()
+}
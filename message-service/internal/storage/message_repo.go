@@ -2,14 +2,50 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+	"github.com/mattn/go-sqlite3"
 )
 
-func CreateMessage(db *sql.DB, channelID, userID int, content string) (*models.Message, error) {
-	res, err := db.Exec("INSERT INTO messages (channel_id, user_id, content) VALUES (?, ?, ?)", channelID, userID, content)
+var (
+	// ErrMessageNotFound is returned when no message has the requested ID.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageDeleted is returned when modifying a message that is a tombstone.
+	ErrMessageDeleted = errors.New("message deleted")
+)
+
+const messageColumns = "id, channel_id, user_id, content, created_at, edited_at, deleted_at, parent_id"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner) (models.Message, error) {
+	var m models.Message
+	var editedAt, deletedAt sql.NullTime
+	var parentID sql.NullInt64
+	err := row.Scan(&m.ID, &m.ChannelID, &m.UserID, &m.Content, &m.CreatedAt, &editedAt, &deletedAt, &parentID)
+	if editedAt.Valid {
+		m.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		m.DeletedAt = &deletedAt.Time
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		m.ParentID = &id
+	}
+	return m, err
+}
+
+// CreateMessage inserts a message. parentID is nil for a top-level message
+// or the ID of the thread root it replies to.
+func CreateMessage(db *sql.DB, channelID, userID int, content string, parentID *int) (*models.Message, error) {
+	res, err := db.Exec("INSERT INTO messages (channel_id, user_id, content, parent_id) VALUES (?, ?, ?, ?)", channelID, userID, content, parentID)
 	if err != nil {
 		return nil, err
 	}
@@ -24,11 +60,66 @@ func CreateMessage(db *sql.DB, channelID, userID int, content string) (*models.M
 		UserID:    userID,
 		Content:   content,
 		CreatedAt: time.Now(),
+		ParentID:  parentID,
 	}, nil
 }
 
-func GetMessagesByChannel(db *sql.DB, channelID int) ([]models.Message, error) {
-	rows, err := db.Query("SELECT id, channel_id, user_id, content, created_at FROM messages WHERE channel_id = ? ORDER BY created_at ASC", channelID)
+// HistoryQuery selects a page of channel history by message ID.
+type HistoryQuery struct {
+	BeforeID       int // only messages with a smaller ID; 0 means no upper bound
+	AfterID        int // only messages with a greater ID; 0 means no lower bound
+	Limit          int
+	Descending     bool // newest first
+	IncludeReplies bool // include thread replies alongside top-level messages
+}
+
+// GetMessagesByChannel returns one page of the channel's messages matching q.
+// In ascending order NextCursor is meant to be passed back as "after" and
+// PrevCursor as "before"; in descending order the roles are swapped.
+func GetMessagesByChannel(db *sql.DB, channelID int, q HistoryQuery) (*models.MessagePage, error) {
+	conditions := []string{"channel_id = ?"}
+	if !q.IncludeReplies {
+		conditions = append(conditions, "parent_id IS NULL")
+	}
+	return getMessagePage(db, conditions, []interface{}{channelID}, q)
+}
+
+// GetThreadReplies returns one page of the replies to a thread root,
+// paginated like GetMessagesByChannel.
+func GetThreadReplies(db *sql.DB, parentID int, q HistoryQuery) (*models.MessagePage, error) {
+	return getMessagePage(db, []string{"parent_id = ?"}, []interface{}{parentID}, q)
+}
+
+// getMessagePage selects a page of messages matching the base conditions
+// and the cursors in q, then attaches thread statistics.
+func getMessagePage(db *sql.DB, baseConditions []string, baseArgs []interface{}, q HistoryQuery) (*models.MessagePage, error) {
+	conditions := append([]string{}, baseConditions...)
+	args := append([]interface{}{}, baseArgs...)
+	if q.AfterID > 0 {
+		conditions = append(conditions, "id > ?")
+		args = append(args, q.AfterID)
+	}
+	if q.BeforeID > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, q.BeforeID)
+	}
+
+	// A "before" cursor in ascending order (or "after" in descending order)
+	// asks for the page preceding it, which has to be read from the cursor
+	// outwards and then flipped back into the requested order.
+	backward := (!q.Descending && q.BeforeID > 0 && q.AfterID == 0) ||
+		(q.Descending && q.AfterID > 0 && q.BeforeID == 0)
+	order := "ASC"
+	if q.Descending != backward {
+		order = "DESC"
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	query := "SELECT " + messageColumns + " FROM messages WHERE " +
+		strings.Join(conditions, " AND ") + " ORDER BY id " + order + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -39,14 +130,263 @@ func GetMessagesByChannel(db *sql.DB, channelID int) ([]models.Message, error) {
 		}
 	}(rows)
 
-	var messages []models.Message
+	messages := []models.Message{}
 	for rows.Next() {
-		var m models.Message
-		err := rows.Scan(&m.ID, &m.ChannelID, &m.UserID, &m.Content, &m.CreatedAt)
+		m, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
 	}
-	return messages, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(messages) > q.Limit
+	if more {
+		messages = messages[:q.Limit]
+	}
+	if backward {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	page := &models.MessagePage{Messages: messages}
+	if len(messages) > 0 {
+		first, last := messages[0].ID, messages[len(messages)-1].ID
+		if backward {
+			// The extra row lies before the page; whether anything follows
+			// it has to be looked up.
+			if more {
+				page.PrevCursor = &first
+			}
+			exists, err := messageExistsBeyond(db, baseConditions, baseArgs, last, !q.Descending)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				page.NextCursor = &last
+			}
+		} else {
+			if more {
+				page.NextCursor = &last
+			}
+			exists, err := messageExistsBeyond(db, baseConditions, baseArgs, first, q.Descending)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				page.PrevCursor = &first
+			}
+		}
+	}
+
+	if err := attachThreadStats(db, page.Messages); err != nil {
+		return nil, err
+	}
+	if err := attachReactions(db, page.Messages); err != nil {
+		return nil, err
+	}
+	if err := attachMentions(db, page.Messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// messageExistsBeyond reports whether a message matching the conditions
+// has an ID greater than id when greater is set, or smaller otherwise.
+func messageExistsBeyond(db *sql.DB, conditions []string, args []interface{}, id int, greater bool) (bool, error) {
+	comparison := "<"
+	if greater {
+		comparison = ">"
+	}
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM messages WHERE " + strings.Join(conditions, " AND ") + " AND id " + comparison + " ?)"
+	err := db.QueryRow(query, append(append([]interface{}{}, args...), id)...).Scan(&exists)
+	return exists, err
+}
+
+// attachThreadStats fills in ReplyCount and LastReplyAt for the top-level
+// messages in the slice. Deleted replies are not counted.
+func attachThreadStats(db *sql.DB, messages []models.Message) error {
+	index := make(map[int]*models.Message)
+	var placeholders []string
+	var args []interface{}
+	for i := range messages {
+		if messages[i].ParentID != nil {
+			continue
+		}
+		index[messages[i].ID] = &messages[i]
+		placeholders = append(placeholders, "?")
+		args = append(args, messages[i].ID)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query("SELECT parent_id, COUNT(*), MAX(created_at) FROM messages WHERE deleted_at IS NULL AND parent_id IN ("+
+		strings.Join(placeholders, ", ")+") GROUP BY parent_id", args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var parentID, count int
+		var lastReply string
+		if err := rows.Scan(&parentID, &count, &lastReply); err != nil {
+			return err
+		}
+		m := index[parentID]
+		m.ReplyCount = count
+		if t, ok := parseTimestamp(lastReply); ok {
+			m.LastReplyAt = &t
+		}
+	}
+	return rows.Err()
+}
+
+// parseTimestamp parses a DATETIME value that SQLite returned as text, as
+// happens for aggregates where the driver cannot see the column type.
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func GetMessageByID(db *sql.DB, id int) (*models.Message, error) {
+	m, err := scanMessage(db.QueryRow("SELECT "+messageColumns+" FROM messages WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	messages := []models.Message{m}
+	if err := attachThreadStats(db, messages); err != nil {
+		return nil, err
+	}
+	if err := attachReactions(db, messages); err != nil {
+		return nil, err
+	}
+	if err := attachMentions(db, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// EditMessage replaces the content of a message, keeping the previous
+// content as a revision in message_edits.
+func EditMessage(db *sql.DB, id int, content string) (*models.Message, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	m, err := scanMessage(tx.QueryRow("SELECT "+messageColumns+" FROM messages WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if m.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec("INSERT INTO message_edits (message_id, content, edited_at) VALUES (?, ?, ?)", id, m.Content, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE messages SET content = ?, edited_at = ? WHERE id = ?", content, now, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	m.Content = content
+	m.EditedAt = &now
+	return &m, nil
+}
+
+// DeleteMessage turns a message into a tombstone: the row stays so that
+// pagination cursors and references keep working, but its content and
+// revision history are erased and it is unpinned.
+func DeleteMessage(db *sql.DB, id, deletedBy int) (*models.Message, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	m, err := scanMessage(tx.QueryRow("SELECT "+messageColumns+" FROM messages WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if m.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec("UPDATE messages SET content = '', deleted_at = ?, deleted_by = ? WHERE id = ?", now, deletedBy, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM message_edits WHERE message_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM reactions WHERE message_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM message_mentions WHERE message_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM pins WHERE message_id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	m.Content = ""
+	m.DeletedAt = &now
+	return &m, nil
+}
+
+// GetMessageEdits returns the prior revisions of a message, oldest first.
+func GetMessageEdits(db *sql.DB, messageID int) ([]models.MessageEdit, error) {
+	rows, err := db.Query("SELECT id, message_id, content, edited_at FROM message_edits WHERE message_id = ? ORDER BY id ASC", messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	edits := []models.MessageEdit{}
+	for rows.Next() {
+		var e models.MessageEdit
+		if err := rows.Scan(&e.ID, &e.MessageID, &e.Content, &e.EditedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, e)
+	}
+	return edits, rows.Err()
 }
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// openTestDB returns a migrated database in a temporary directory. The
+// schema needs FTS5, so the test is skipped without -tags sqlite_fts5.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := InitDB(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Skip("database unavailable: " + err.Error())
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := RunMigrations(db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func messageIDs(messages []models.Message) []int {
+	ids := []int{}
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestGetMessagesByChannelPagesBack(t *testing.T) {
+	db := openTestDB(t)
+	channel, err := CreateChannel(db, "general", false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if _, err := CreateMessage(db, channel.ID, 1, "hello", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		descending bool
+		// next and prev set the query cursors for following NextCursor
+		// and PrevCursor.
+		next, prev func(*HistoryQuery, int)
+		wantPages  [][]int
+	}{
+		{
+			name:      "ascending",
+			next:      func(q *HistoryQuery, id int) { q.AfterID = id },
+			prev:      func(q *HistoryQuery, id int) { q.BeforeID = id },
+			wantPages: [][]int{{1, 2, 3}, {4, 5, 6}, {1, 2, 3}},
+		},
+		{
+			name:       "descending",
+			descending: true,
+			next:       func(q *HistoryQuery, id int) { q.BeforeID = id },
+			prev:       func(q *HistoryQuery, id int) { q.AfterID = id },
+			wantPages:  [][]int{{7, 6, 5}, {4, 3, 2}, {7, 6, 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := GetMessagesByChannel(db, channel.ID, HistoryQuery{Limit: 3, Descending: tt.descending})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.NextCursor == nil {
+				t.Fatal("first page has no next cursor")
+			}
+
+			q := HistoryQuery{Limit: 3, Descending: tt.descending}
+			tt.next(&q, *first.NextCursor)
+			second, err := GetMessagesByChannel(db, channel.ID, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second.PrevCursor == nil {
+				t.Fatal("second page has no prev cursor")
+			}
+
+			q = HistoryQuery{Limit: 3, Descending: tt.descending}
+			tt.prev(&q, *second.PrevCursor)
+			back, err := GetMessagesByChannel(db, channel.ID, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := [][]int{messageIDs(first.Messages), messageIDs(second.Messages), messageIDs(back.Messages)}
+			if !reflect.DeepEqual(got, tt.wantPages) {
+				t.Fatalf("pages = %v, want %v", got, tt.wantPages)
+			}
+			if back.PrevCursor != nil {
+				t.Errorf("page back at the start has prev cursor %d", *back.PrevCursor)
+			}
+			if back.NextCursor == nil || *back.NextCursor != *first.NextCursor {
+				t.Errorf("page back has next cursor %v, want %d", back.NextCursor, *first.NextCursor)
+			}
+		})
+	}
+}
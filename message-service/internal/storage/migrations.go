@@ -9,6 +9,8 @@ import (
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
 )
 
+// RunMigrations applies every .sql file in migrationsDir that is not yet
+// recorded in schema_migrations, in file name order.
 func RunMigrations(db *sql.DB, migrationsDir string) error {
 	utils.Info(fmt.Sprintf("Starting to run migrations from directory: %s", migrationsDir))
 	entries, err := os.ReadDir(migrationsDir)
@@ -17,8 +19,28 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 		return err
 	}
 
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		utils.Error(fmt.Sprintf("Failed to create schema_migrations table: %v", err))
+		return err
+	}
+
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			var applied bool
+			err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE name = ?)", entry.Name()).Scan(&applied)
+			if err != nil {
+				utils.Error(fmt.Sprintf("Failed to check migration %s: %v", entry.Name(), err))
+				return err
+			}
+			if applied {
+				utils.Info(fmt.Sprintf("Skipping already applied migration: %s", entry.Name()))
+				continue
+			}
+
 			path := migrationsDir + "/" + entry.Name()
 			utils.Info(fmt.Sprintf("Running migration: %s", path))
 			content, err := os.ReadFile(path)
@@ -26,11 +48,22 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 				utils.Error(fmt.Sprintf("Failed to read migration file %s: %v", path, err))
 				return err
 			}
-			_, err = db.Exec(string(content))
+
+			tx, err := db.Begin()
+			if err != nil {
+				return err
+			}
+			if _, err = tx.Exec(string(content)); err == nil {
+				_, err = tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", entry.Name())
+			}
 			if err != nil {
+				_ = tx.Rollback()
 				utils.Error(fmt.Sprintf("Failed to execute migration %s: %v", entry.Name(), err))
 				return fmt.Errorf("failed to run migration %s: %v", entry.Name(), err)
 			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
 			utils.Info(fmt.Sprintf("Successfully applied migration: %s", path))
 		}
 	}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/genryusaishigikuni/messenger/message-service/internal/permissions"
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// baselineMigrations are the migrations the first release shipped with. It
+// ran them on every start without recording them in schema_migrations.
+var baselineMigrations = []string{"001_init.sql", "002_indexes.sql"}
+
+// TestMigrateBaselineDatabase upgrades a database in the shape the first
+// release left it: channels and messages only, no members or roles.
+func TestMigrateBaselineDatabase(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Skip("database unavailable: " + err.Error())
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for _, name := range baselineMigrations {
+		content, err := os.ReadFile(filepath.Join("../../migrations", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// penguinz: user 5 posted first; sigmas: one poster; quiet: nobody posted
+	for _, stmt := range []string{
+		"INSERT INTO channels (id, name) VALUES (1, 'penguinz'), (2, 'sigmas'), (3, 'quiet')",
+		"INSERT INTO messages (channel_id, user_id, content) VALUES (1, 5, 'first'), (1, 3, 'second'), (1, 5, 'third'), (2, 7, 'hi')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RunMigrations(db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := []struct {
+		channelID, userID int
+		want              string
+	}{
+		{1, 5, permissions.RoleOwner},
+		{1, 3, permissions.RoleMember},
+		{2, 7, permissions.RoleOwner},
+	}
+	for _, tt := range roles {
+		got, err := GetMemberRole(db, tt.channelID, tt.userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("role of user %d in channel %d = %q, want %q", tt.userID, tt.channelID, got, tt.want)
+		}
+	}
+
+	// Nobody posted in quiet, so an operator has to name its owner
+	ownerless, err := GetOwnerlessChannels(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := channelNames(ownerless); !reflect.DeepEqual(got, []string{"quiet"}) {
+		t.Fatalf("ownerless channels = %v, want [quiet]", got)
+	}
+	if err := ClaimChannelOwnership(db, 3, 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimChannelOwnership(db, 3, 10); !errors.Is(err, ErrChannelHasOwner) {
+		t.Fatalf("second claim: err = %v, want ErrChannelHasOwner", err)
+	}
+	if role, err := GetMemberRole(db, 3, 9); err != nil || role != permissions.RoleOwner {
+		t.Fatalf("claimed role = %q, %v; want owner", role, err)
+	}
+	if ownerless, err := GetOwnerlessChannels(db); err != nil || len(ownerless) != 0 {
+		t.Fatalf("ownerless channels after claim = %v, %v; want none", channelNames(ownerless), err)
+	}
+
+	// Starting again must not re-run anything
+	if err := RunMigrations(db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func channelNames(channels []models.Channel) []string {
+	names := []string{}
+	for _, c := range channels {
+		names = append(names, c.Name)
+	}
+	return names
+}
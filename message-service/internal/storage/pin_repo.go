@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// ErrPinLimitReached is returned when a channel already has the maximum
+// number of pinned messages.
+var ErrPinLimitReached = errors.New("pin limit reached")
+
+// PinMessage pins a message in its channel unless the channel already has
+// maxPins pins. It reports false, with the existing pin, when the message
+// was already pinned.
+func PinMessage(db *sql.DB, channelID, messageID, userID, maxPins int) (*models.Pin, bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	pin := models.Pin{ChannelID: channelID, MessageID: messageID}
+	err = tx.QueryRow("SELECT pinned_by, pinned_at FROM pins WHERE message_id = ?", messageID).Scan(&pin.PinnedBy, &pin.PinnedAt)
+	if err == nil {
+		return &pin, false, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM pins WHERE channel_id = ?", channelID).Scan(&count); err != nil {
+		return nil, false, err
+	}
+	if count >= maxPins {
+		return nil, false, ErrPinLimitReached
+	}
+
+	pin.PinnedBy = userID
+	pin.PinnedAt = time.Now().UTC()
+	if _, err := tx.Exec("INSERT INTO pins (message_id, channel_id, pinned_by, pinned_at) VALUES (?, ?, ?, ?)",
+		messageID, channelID, userID, pin.PinnedAt); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return &pin, true, nil
+}
+
+// UnpinMessage removes a message's pin from the channel. It reports false
+// when the message was not pinned there.
+func UnpinMessage(db *sql.DB, channelID, messageID int) (bool, error) {
+	res, err := db.Exec("DELETE FROM pins WHERE channel_id = ? AND message_id = ?", channelID, messageID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetPins lists the channel's pinned messages, most recently pinned first.
+func GetPins(db *sql.DB, channelID int) ([]models.Pin, error) {
+	rows, err := db.Query("SELECT message_id, pinned_by, pinned_at FROM pins WHERE channel_id = ? ORDER BY pinned_at DESC, rowid DESC", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	pins := []models.Pin{}
+	for rows.Next() {
+		pin := models.Pin{ChannelID: channelID}
+		if err := rows.Scan(&pin.MessageID, &pin.PinnedBy, &pin.PinnedAt); err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return pins, attachPinnedMessages(db, pins)
+}
+
+// attachPinnedMessages loads the message of every pin in the slice.
+func attachPinnedMessages(db *sql.DB, pins []models.Pin) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	placeholders := make([]string, 0, len(pins))
+	args := make([]interface{}, 0, len(pins))
+	for _, pin := range pins {
+		placeholders = append(placeholders, "?")
+		args = append(args, pin.MessageID)
+	}
+
+	rows, err := db.Query("SELECT "+messageColumns+" FROM messages WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	var messages []models.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := attachThreadStats(db, messages); err != nil {
+		return err
+	}
+	if err := attachReactions(db, messages); err != nil {
+		return err
+	}
+	if err := attachMentions(db, messages); err != nil {
+		return err
+	}
+	index := make(map[int]*models.Message, len(messages))
+	for i := range messages {
+		index[messages[i].ID] = &messages[i]
+	}
+	for i := range pins {
+		pins[i].Message = index[pins[i].MessageID]
+	}
+	return nil
+}
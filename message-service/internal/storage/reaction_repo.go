@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// AddReaction records userID reacting to a message with emoji. It reports
+// false when that reaction already existed.
+func AddReaction(db *sql.DB, messageID, userID int, emoji string) (bool, error) {
+	res, err := db.Exec("INSERT OR IGNORE INTO reactions (message_id, user_id, emoji) VALUES (?, ?, ?)", messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveReaction deletes userID's emoji reaction from a message. It reports
+// false when there was no such reaction.
+func RemoveReaction(db *sql.DB, messageID, userID int, emoji string) (bool, error) {
+	res, err := db.Exec("DELETE FROM reactions WHERE message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountReactions returns how many users reacted to a message with emoji.
+func CountReactions(db *sql.DB, messageID int, emoji string) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM reactions WHERE message_id = ? AND emoji = ?", messageID, emoji).Scan(&count)
+	return count, err
+}
+
+// attachReactions fills in the aggregated reactions of every message in the
+// slice. Emojis are listed in the order they were first used on a message.
+func attachReactions(db *sql.DB, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Message, len(messages))
+	placeholders := make([]string, 0, len(messages))
+	args := make([]interface{}, 0, len(messages))
+	for i := range messages {
+		index[messages[i].ID] = &messages[i]
+		placeholders = append(placeholders, "?")
+		args = append(args, messages[i].ID)
+	}
+
+	rows, err := db.Query("SELECT message_id, emoji, user_id FROM reactions WHERE message_id IN ("+
+		strings.Join(placeholders, ", ")+") ORDER BY message_id, rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var messageID, userID int
+		var emoji string
+		if err := rows.Scan(&messageID, &emoji, &userID); err != nil {
+			return err
+		}
+		m := index[messageID]
+		found := false
+		for i := range m.Reactions {
+			if m.Reactions[i].Emoji == emoji {
+				m.Reactions[i].Count++
+				m.Reactions[i].UserIDs = append(m.Reactions[i].UserIDs, userID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.Reactions = append(m.Reactions, models.ReactionSummary{Emoji: emoji, Count: 1, UserIDs: []int{userID}})
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+// MarkChannelRead moves userID's read position in the channel forward to
+// messageID. An older messageID leaves the position unchanged. It returns
+// the resulting receipt and whether the position moved.
+func MarkChannelRead(db *sql.DB, channelID, userID, messageID int) (*models.ReadReceipt, bool, error) {
+	now := time.Now().UTC()
+	res, err := db.Exec(`INSERT INTO channel_reads (channel_id, user_id, last_read_message_id, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(channel_id, user_id) DO UPDATE SET last_read_message_id = excluded.last_read_message_id, updated_at = excluded.updated_at
+		WHERE excluded.last_read_message_id > channel_reads.last_read_message_id`,
+		channelID, userID, messageID, now)
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	receipt := models.ReadReceipt{ChannelID: channelID, UserID: userID}
+	err = db.QueryRow("SELECT last_read_message_id, updated_at FROM channel_reads WHERE channel_id = ? AND user_id = ?", channelID, userID).
+		Scan(&receipt.LastReadMessageID, &receipt.ReadAt)
+	if err != nil {
+		return nil, false, err
+	}
+	return &receipt, n > 0, nil
+}
+
+// GetLatestMessageID returns the ID of the newest message in the channel,
+// or 0 when it has none.
+func GetLatestMessageID(db *sql.DB, channelID int) (int, error) {
+	var id sql.NullInt64
+	err := db.QueryRow("SELECT MAX(id) FROM messages WHERE channel_id = ?", channelID).Scan(&id)
+	return int(id.Int64), err
+}
+
+// GetChannelReads lists the read positions of every user who has read the channel.
+func GetChannelReads(db *sql.DB, channelID int) ([]models.ReadReceipt, error) {
+	rows, err := db.Query("SELECT channel_id, user_id, last_read_message_id, updated_at FROM channel_reads WHERE channel_id = ? ORDER BY user_id ASC", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	receipts := []models.ReadReceipt{}
+	for rows.Next() {
+		var r models.ReadReceipt
+		if err := rows.Scan(&r.ChannelID, &r.UserID, &r.LastReadMessageID, &r.ReadAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+// AttachUnreadCounts fills in userID's read position and unread count for
+// every channel in the slice. Unread messages are live messages by other
+// users after the read position; a channel never read counts all of them.
+// The mention count covers the unread messages that mention the user by
+// name or with @channel.
+func AttachUnreadCounts(db *sql.DB, userID int, channels []models.Channel) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Channel, len(channels))
+	placeholders := make([]string, 0, len(channels))
+	args := []interface{}{userID, userID, userID, userID}
+	for i := range channels {
+		unread, mentioned, lastRead := 0, 0, 0
+		channels[i].UnreadCount = &unread
+		channels[i].MentionCount = &mentioned
+		channels[i].LastReadMessageID = &lastRead
+		index[channels[i].ID] = &channels[i]
+		placeholders = append(placeholders, "?")
+		args = append(args, channels[i].ID)
+	}
+
+	rows, err := db.Query(`SELECT c.id, COALESCE(r.last_read_message_id, 0),
+			(SELECT COUNT(*) FROM messages m WHERE m.channel_id = c.id AND m.id > COALESCE(r.last_read_message_id, 0)
+				AND m.deleted_at IS NULL AND m.user_id != ?),
+			(SELECT COUNT(*) FROM messages m WHERE m.channel_id = c.id AND m.id > COALESCE(r.last_read_message_id, 0)
+				AND m.deleted_at IS NULL AND m.user_id != ?
+				AND EXISTS (SELECT 1 FROM message_mentions mm WHERE mm.message_id = m.id
+					AND ((mm.kind = 'user' AND mm.user_id = ?) OR mm.kind = 'channel')))
+		FROM channels c LEFT JOIN channel_reads r ON r.channel_id = c.id AND r.user_id = ?
+		WHERE c.id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var channelID, lastRead, unread, mentioned int
+		if err := rows.Scan(&channelID, &lastRead, &unread, &mentioned); err != nil {
+			return err
+		}
+		c := index[channelID]
+		*c.LastReadMessageID = lastRead
+		*c.UnreadCount = unread
+		*c.MentionCount = mentioned
+	}
+	return rows.Err()
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/genryusaishigikuni/messenger/message-service/pkg/models"
+)
+
+var ErrEmptySearch = errors.New("search query has no terms")
+
+// Highlight markers placed around matching terms in search snippets.
+const (
+	SnippetMatchStart = "<mark>"
+	SnippetMatchEnd   = "</mark>"
+)
+
+// FTS5 marks matches with these control characters, which survive HTML
+// escaping, before they are swapped for the highlight tags.
+const (
+	rawMatchStart = "\x02"
+	rawMatchEnd   = "\x03"
+)
+
+// SearchQuery filters a full-text message search. Zero values leave a
+// filter unset.
+type SearchQuery struct {
+	Text      string
+	UserID    int // the caller; only channels they can read are searched
+	ChannelID int
+	AuthorID  int
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	BeforeID  int
+	Limit     int
+}
+
+// snippetScanner scans a message row followed by its snippet column.
+type snippetScanner struct {
+	rows    *sql.Rows
+	snippet *string
+}
+
+func (s snippetScanner) Scan(dest ...interface{}) error {
+	return s.rows.Scan(append(dest, s.snippet)...)
+}
+
+// SearchMessages returns one page of live messages matching q.Text, newest first.
+func SearchMessages(db *sql.DB, q SearchQuery) (*models.SearchPage, error) {
+	match, err := buildMatchQuery(q.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := strings.Split(messageColumns, ", ")
+	for i := range columns {
+		columns[i] = "m." + columns[i]
+	}
+
+	conditions := []string{
+		"messages_fts MATCH ?",
+		"m.deleted_at IS NULL",
+		// Channels the caller may read: public ones and those they belong to
+		"m.channel_id IN (SELECT id FROM channels WHERE (kind != ? AND private = 0) OR id IN " +
+			"(SELECT channel_id FROM channel_members WHERE user_id = ?))",
+	}
+	args := []interface{}{match, models.ChannelKindDirect, q.UserID}
+	if q.ChannelID > 0 {
+		conditions = append(conditions, "m.channel_id = ?")
+		args = append(args, q.ChannelID)
+	}
+	if q.AuthorID > 0 {
+		conditions = append(conditions, "m.user_id = ?")
+		args = append(args, q.AuthorID)
+	}
+	// created_at holds UTC text in SQLite's default format, which sorts chronologically
+	if !q.From.IsZero() {
+		conditions = append(conditions, "m.created_at >= ?")
+		args = append(args, q.From.UTC().Format(time.DateTime))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "m.created_at < ?")
+		args = append(args, q.To.UTC().Format(time.DateTime))
+	}
+	if q.BeforeID > 0 {
+		conditions = append(conditions, "m.id < ?")
+		args = append(args, q.BeforeID)
+	}
+
+	query := "SELECT " + strings.Join(columns, ", ") + ", snippet(messages_fts, 0, ?, ?, '…', 16) " +
+		"FROM messages_fts JOIN messages m ON m.id = messages_fts.rowid WHERE " +
+		strings.Join(conditions, " AND ") + " ORDER BY m.id DESC LIMIT ?"
+	args = append([]interface{}{rawMatchStart, rawMatchEnd}, args...)
+	args = append(args, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(rows)
+
+	var messages []models.Message
+	var snippets []string
+	for rows.Next() {
+		var snippet string
+		m, err := scanMessage(snippetScanner{rows: rows, snippet: &snippet})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+		snippets = append(snippets, highlightSnippet(snippet))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &models.SearchPage{Results: []models.SearchResult{}}
+	if len(messages) > q.Limit {
+		messages = messages[:q.Limit]
+		next := messages[q.Limit-1].ID
+		page.NextCursor = &next
+	}
+	if err := attachThreadStats(db, messages); err != nil {
+		return nil, err
+	}
+	if err := attachReactions(db, messages); err != nil {
+		return nil, err
+	}
+	if err := attachMentions(db, messages); err != nil {
+		return nil, err
+	}
+	for i, m := range messages {
+		page.Results = append(page.Results, models.SearchResult{Message: m, Snippet: snippets[i]})
+	}
+	return page, nil
+}
+
+// highlightSnippet HTML-escapes a raw FTS5 snippet and turns its match
+// markers into highlight tags, so the result is safe to render as HTML.
+// Message content may itself contain the marker bytes, so markers only open
+// or close a tag when that keeps the tags balanced, and every other control
+// character is dropped.
+func highlightSnippet(raw string) string {
+	var b strings.Builder
+	open := false
+	for _, r := range raw {
+		switch {
+		case string(r) == rawMatchStart:
+			if !open {
+				b.WriteString(SnippetMatchStart)
+				open = true
+			}
+		case string(r) == rawMatchEnd:
+			if open {
+				b.WriteString(SnippetMatchEnd)
+				open = false
+			}
+		case unicode.IsControl(r) && r != '\n' && r != '\t':
+		default:
+			b.WriteString(html.EscapeString(string(r)))
+		}
+	}
+	if open {
+		b.WriteString(SnippetMatchEnd)
+	}
+	return b.String()
+}
+
+// buildMatchQuery turns free text into an FTS5 query matching messages that
+// contain every word. Words are quoted so FTS5 operators in user input are
+// taken literally; a trailing * still makes a word a prefix match.
+func buildMatchQuery(text string) (string, error) {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return "", ErrEmptySearch
+	}
+	return strings.Join(terms, " "), nil
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "testing"
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"say \x02hello\x03 there", "say <mark>hello</mark> there"},
+		{"<script>\x02alert\x03(1)</script>", "&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;"},
+		{"\x02\"quoted\"\x03 & more", "<mark>&#34;quoted&#34;</mark> &amp; more"},
+		{"stray \x03end \x02hit\x03", "stray end <mark>hit</mark>"},
+		{"\x02hit\x03 then \x02\x02open", "<mark>hit</mark> then <mark>open</mark>"},
+		{"bell\x07 and\x00 nul\nline", "bell and nul\nline"},
+	}
+	for _, tt := range tests {
+		if got := highlightSnippet(tt.raw); got != tt.want {
+			t.Errorf("highlightSnippet(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/genryusaishigikuni/messenger/message-service/pkg/utils"
@@ -15,6 +16,19 @@ func InitDB(path string) (*sql.DB, error) {
 		utils.Error(fmt.Sprintf("Failed to open database at %s: %v", path, err))
 		return nil, err
 	}
+
+	// Message search relies on FTS5, which go-sqlite3 only compiles in
+	// with the sqlite_fts5 build tag
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		utils.Error(fmt.Sprintf("Failed to query SQLite compile options: %v", err))
+		return nil, err
+	}
+	if !fts5 {
+		utils.Error("SQLite was built without FTS5")
+		return nil, errors.New("sqlite3 driver lacks FTS5; build with -tags sqlite_fts5")
+	}
+
 	utils.Info("Database connection initialized successfully")
 	return db, nil
 }
@@ -0,0 +1,11 @@
+ALTER TABLE messages ADD COLUMN edited_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+                                             id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                             message_id INTEGER NOT NULL,
+                                             content TEXT NOT NULL,
+                                             edited_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                             FOREIGN KEY(message_id) REFERENCES messages(id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message_id ON message_edits(message_id);
@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+ALTER TABLE messages ADD COLUMN deleted_by INTEGER;
+
+CREATE TABLE IF NOT EXISTS channel_moderators (
+                                                  channel_id INTEGER NOT NULL,
+                                                  user_id INTEGER NOT NULL,
+                                                  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                                  PRIMARY KEY (channel_id, user_id),
+                                                  FOREIGN KEY(channel_id) REFERENCES channels(id)
+    );
@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN parent_id INTEGER REFERENCES messages(id);
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent_id_id ON messages(parent_id, id);
@@ -0,0 +1,6 @@
+ALTER TABLE channels ADD COLUMN kind TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE channels ADD COLUMN dm_user_a INTEGER;
+ALTER TABLE channels ADD COLUMN dm_user_b INTEGER;
+
+-- One conversation per user pair; dm_user_a is always the smaller user ID
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channels_dm_pair ON channels(dm_user_a, dm_user_b) WHERE kind = 'dm';
@@ -0,0 +1,16 @@
+ALTER TABLE channels ADD COLUMN private INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS channel_members (
+                                               channel_id INTEGER NOT NULL,
+                                               user_id INTEGER NOT NULL,
+                                               joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                               PRIMARY KEY (channel_id, user_id),
+                                               FOREIGN KEY(channel_id) REFERENCES channels(id)
+    );
+CREATE INDEX IF NOT EXISTS idx_channel_members_user_id ON channel_members(user_id);
+
+-- Existing moderators, authors and DM participants keep their access
+INSERT OR IGNORE INTO channel_members (channel_id, user_id) SELECT channel_id, user_id FROM channel_moderators;
+INSERT OR IGNORE INTO channel_members (channel_id, user_id) SELECT DISTINCT channel_id, user_id FROM messages;
+INSERT OR IGNORE INTO channel_members (channel_id, user_id) SELECT id, dm_user_a FROM channels WHERE kind = 'dm';
+INSERT OR IGNORE INTO channel_members (channel_id, user_id) SELECT id, dm_user_b FROM channels WHERE kind = 'dm';
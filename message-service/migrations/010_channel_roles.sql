@@ -0,0 +1,13 @@
+ALTER TABLE channel_members ADD COLUMN role TEXT NOT NULL DEFAULT 'member';
+ALTER TABLE channels ADD COLUMN default_role TEXT NOT NULL DEFAULT 'member';
+
+-- Moderators keep their rights; the earliest moderator of each channel is its creator
+UPDATE channel_members SET role = 'moderator'
+WHERE EXISTS (SELECT 1 FROM channel_moderators m
+              WHERE m.channel_id = channel_members.channel_id AND m.user_id = channel_members.user_id);
+UPDATE channel_members SET role = 'owner'
+WHERE user_id = (SELECT m.user_id FROM channel_moderators m
+                 WHERE m.channel_id = channel_members.channel_id
+                 ORDER BY m.created_at ASC, m.user_id ASC LIMIT 1);
+
+DROP TABLE IF EXISTS channel_moderators;
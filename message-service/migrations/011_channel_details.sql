@@ -0,0 +1,3 @@
+ALTER TABLE channels ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN archived_at DATETIME;
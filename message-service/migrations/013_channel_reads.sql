@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS channel_reads (
+                                             channel_id INTEGER NOT NULL,
+                                             user_id INTEGER NOT NULL,
+                                             last_read_message_id INTEGER NOT NULL,
+                                             updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                             PRIMARY KEY (channel_id, user_id),
+                                             FOREIGN KEY(channel_id) REFERENCES channels(id)
+    );
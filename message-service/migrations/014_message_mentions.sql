@@ -0,0 +1,10 @@
+-- Mentions are resolved against the auth service when a message is posted or
+-- edited, so messages that predate this table have none.
+CREATE TABLE IF NOT EXISTS message_mentions (
+                                                message_id INTEGER NOT NULL,
+                                                kind TEXT NOT NULL,
+                                                user_id INTEGER NOT NULL DEFAULT 0,
+                                                PRIMARY KEY (message_id, kind, user_id),
+                                                FOREIGN KEY(message_id) REFERENCES messages(id)
+    );
+CREATE INDEX IF NOT EXISTS idx_message_mentions_user_id ON message_mentions(user_id, message_id);
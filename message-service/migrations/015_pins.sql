@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pins (
+    message_id INTEGER PRIMARY KEY REFERENCES messages(id),
+    channel_id INTEGER NOT NULL REFERENCES channels(id),
+    pinned_by INTEGER NOT NULL,
+    pinned_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pins_channel_id_pinned_at ON pins(channel_id, pinned_at);
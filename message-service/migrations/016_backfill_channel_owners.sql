@@ -0,0 +1,12 @@
+-- Channels created before moderators were recorded have no moderator row, so
+-- 010 left them without an owner. The earliest poster who is still a member
+-- takes over; channels nobody has posted in are handed to an owner through
+-- POST /api/internal/channels/{id}/owner.
+UPDATE channel_members SET role = 'owner'
+WHERE channel_id IN (SELECT id FROM channels WHERE kind != 'dm')
+  AND NOT EXISTS (SELECT 1 FROM channel_members o
+                  WHERE o.channel_id = channel_members.channel_id AND o.role = 'owner')
+  AND user_id = (SELECT m.user_id FROM messages m
+                 JOIN channel_members cm ON cm.channel_id = m.channel_id AND cm.user_id = m.user_id
+                 WHERE m.channel_id = channel_members.channel_id
+                 ORDER BY m.id ASC LIMIT 1);
@@ -2,8 +2,54 @@ package models
 
 import "time"
 
+// Channel kinds.
+const (
+	ChannelKindPublic = "public"
+	ChannelKindDirect = "dm"
+)
+
 type Channel struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Topic        string    `json:"topic"`
+	Description  string    `json:"description"`
+	Kind         string    `json:"kind"`
+	Private      bool      `json:"private"`                // visible and readable only by members
+	Participants []int     `json:"participants,omitempty"` // the two users of a direct message channel
+	DefaultRole  string    `json:"default_role"`           // role given to users who join or are invited
+	CreatedAt    time.Time `json:"created_at"`
+
+	// ArchivedAt is set while the channel is archived, which makes it
+	// read-only and hides it from the default channel listing.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+
+	// Read state of the requesting user, set only when asked for
+	LastReadMessageID *int `json:"last_read_message_id,omitempty"`
+	UnreadCount       *int `json:"unread_count,omitempty"`
+	MentionCount      *int `json:"mention_count,omitempty"` // unread messages mentioning the user
+}
+
+// ChannelMember is a user's membership in a channel.
+type ChannelMember struct {
+	ChannelID int       `json:"channel_id"`
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// MemberEvent describes a membership change. ActorID is the user who made
+// the change, which differs from UserID for invites and kicks.
+type MemberEvent struct {
+	ChannelID int    `json:"channel_id"`
+	UserID    int    `json:"user_id"`
+	ActorID   int    `json:"actor_id"`
+	Role      string `json:"role,omitempty"`
+}
+
+// ReadReceipt records how far a user has read in a channel.
+type ReadReceipt struct {
+	ChannelID         int       `json:"channel_id"`
+	UserID            int       `json:"user_id"`
+	LastReadMessageID int       `json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
 }
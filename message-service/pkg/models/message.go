@@ -3,9 +3,109 @@ package models
 import "time"
 
 type Message struct {
-	ID        int       `json:"id"`
+	ID        int        `json:"id"`
+	ChannelID int        `json:"channel_id"`
+	UserID    int        `json:"user_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set on tombstones, whose content is cleared
+
+	// Threads: ParentID is set on replies; the counters are set on thread roots.
+	ParentID    *int       `json:"parent_id,omitempty"`
+	ReplyCount  int        `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
+	Mentions  []Mention         `json:"mentions,omitempty"`
+}
+
+// Mention kinds.
+const (
+	MentionUser    = "user"    // @username
+	MentionChannel = "channel" // @channel: every member of the channel
+	MentionHere    = "here"    // @here: members who are online
+)
+
+// Mention is one mention in a message. UserID is set for user mentions only.
+type Mention struct {
+	Kind   string `json:"kind"`
+	UserID int    `json:"user_id,omitempty"`
+}
+
+// MentionEvent is delivered to a mentioned user. Kind is how they were
+// mentioned; a user mentioned both by name and channel-wide gets "user".
+type MentionEvent struct {
+	Kind    string  `json:"kind"`
+	Message Message `json:"message"`
+}
+
+// ReactionSummary aggregates the reactions on a message for one emoji.
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	UserIDs []int  `json:"user_ids"`
+}
+
+// ReactionEvent describes a single reaction being added or removed. Count is
+// the number of reactions with that emoji on the message afterwards.
+type ReactionEvent struct {
+	MessageID int    `json:"message_id"`
+	ChannelID int    `json:"channel_id"`
+	UserID    int    `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+}
+
+// Pin is a message pinned in a channel, together with the message itself.
+type Pin struct {
 	ChannelID int       `json:"channel_id"`
-	UserID    int       `json:"user_id"`
+	MessageID int       `json:"message_id"`
+	PinnedBy  int       `json:"pinned_by"`
+	PinnedAt  time.Time `json:"pinned_at"`
+	Message   *Message  `json:"message,omitempty"`
+}
+
+// UnpinEvent describes a message being unpinned by ActorID.
+type UnpinEvent struct {
+	ChannelID int `json:"channel_id"`
+	MessageID int `json:"message_id"`
+	ActorID   int `json:"actor_id"`
+}
+
+// Thread is a thread root together with one page of its replies.
+type Thread struct {
+	Parent  Message     `json:"parent"`
+	Replies MessagePage `json:"replies"`
+}
+
+// MessageEdit is a prior revision of a message, kept when the message is edited.
+type MessageEdit struct {
+	ID        int       `json:"id"`
+	MessageID int       `json:"message_id"`
 	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// MessagePage is one page of channel history. NextCursor continues in the
+// requested order and PrevCursor goes back the other way; both are message
+// IDs and are nil when there is nothing further in that direction.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor *int      `json:"next_cursor"`
+	PrevCursor *int      `json:"prev_cursor"`
+}
+
+// SearchResult is a message matching a search, with the matching part of
+// its content highlighted in Snippet.
+type SearchResult struct {
+	Message Message `json:"message"`
+	Snippet string  `json:"snippet"`
+}
+
+// SearchPage is one page of search results, newest first. NextCursor is
+// passed back as "before" to fetch older results.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor *int           `json:"next_cursor"`
 }
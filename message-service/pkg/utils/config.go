@@ -1,11 +1,20 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 type Config struct {
 	DatabasePath   string
 	ServerPort     string
 	AuthServiceURL string
+
+	MaxPinsPerChannel int
+	// InternalAPIToken authenticates the other services and operators on
+	// the internal endpoints. Those endpoints refuse every call while it is
+	// unset.
+	InternalAPIToken string
 }
 
 func LoadConfig() Config {
@@ -24,9 +33,22 @@ func LoadConfig() Config {
 		authURL = "http://localhost:8082"
 	}
 
+	maxPins, err := strconv.Atoi(os.Getenv("MAX_PINS_PER_CHANNEL"))
+	if err != nil || maxPins < 1 {
+		maxPins = 50
+	}
+
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		Error("INTERNAL_API_TOKEN not set, internal endpoints will reject all requests")
+	}
+
 	return Config{
 		DatabasePath:   dbPath,
 		ServerPort:     port,
 		AuthServiceURL: authURL,
+
+		MaxPinsPerChannel: maxPins,
+		InternalAPIToken:  internalToken,
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/handlers"
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/memory"
 	"github.com/genryusaishigikuni/messenger/presence-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
 	"github.com/gorilla/mux"
 )
 
@@ -19,13 +21,18 @@ func main() {
 	utils.Info("Presence store initialized successfully.")
 
 	utils.Info("Setting up routes...")
+	verifier := tokenverify.New(cfg.AuthServiceURL)
 	r := mux.NewRouter()
 	r.HandleFunc("/api/presence", handlers.GetPresenceHandler(store)).Methods("GET")
 	utils.Info("Route set for GET /api/presence")
-	r.HandleFunc("/api/presence/join", handlers.JoinHandler(store, cfg.AuthServiceURL)).Methods("POST")
+	r.HandleFunc("/api/presence/join", handlers.JoinHandler(store, verifier)).Methods("POST")
 	utils.Info("Route set for POST /api/presence/join")
-	r.HandleFunc("/api/presence/leave", handlers.LeaveHandler(store, cfg.AuthServiceURL)).Methods("POST")
+	r.HandleFunc("/api/presence/leave", handlers.LeaveHandler(store, verifier)).Methods("POST")
 	utils.Info("Route set for POST /api/presence/leave")
+	r.HandleFunc("/api/internal/presence/leave", internalauth.Require(cfg.InternalAPIToken, handlers.InternalLeaveHandler(store))).Methods("POST")
+	utils.Info("Route set for POST /api/internal/presence/leave")
+	r.HandleFunc("/api/internal/sessions/revoked", internalauth.Require(cfg.InternalAPIToken, verifier.RevocationHandler(nil))).Methods("POST")
+	utils.Info("Route set for POST /api/internal/sessions/revoked")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
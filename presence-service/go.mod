@@ -2,4 +2,8 @@ module github.com/genryusaishigikuni/messenger/presence-service
 
 go 1.23.4
 
-require github.com/gorilla/mux v1.8.1 // indirect
+require github.com/gorilla/mux v1.8.1
+
+require github.com/genryusaishigikuni/messenger/tokenverify v0.0.0
+
+replace github.com/genryusaishigikuni/messenger/tokenverify => ../tokenverify
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/genryusaishigikuni/messenger/presence-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify/internalauth"
 )
 
 type PresenceEvent struct {
@@ -43,8 +44,16 @@ func BroadcastEvent(event string, userID, channelID int) {
 	}
 	utils.Info("Presence event marshaled successfully.")
 
+	req, err := http.NewRequest("POST", gatewayURL+"/api/presence/event", bytes.NewBuffer(data))
+	if err != nil {
+		utils.Error("Failed to create presence event request: " + err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	internalauth.Sign(req, os.Getenv("INTERNAL_API_TOKEN"))
+
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Post(gatewayURL+"/api/presence/event", "application/json", bytes.NewBuffer(data))
+	resp, err := client.Do(req)
 	if err != nil {
 		utils.Error("Failed to send presence event to gateway: " + err.Error())
 		return
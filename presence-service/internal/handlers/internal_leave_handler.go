@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/genryusaishigikuni/messenger/presence-service/internal/memory"
+	"github.com/genryusaishigikuni/messenger/presence-service/pkg/utils"
+)
+
+type internalLeaveRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// InternalLeaveHandler POST /api/internal/presence/leave marks a user offline
+// by ID. The gateway calls it when a user's last socket closes, by which time
+// the access token the socket was opened with may already have expired.
+func InternalLeaveHandler(store *memory.PresenceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("Handling internal user leave request...")
+
+		var req internalLeaveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error("Failed to decode internal leave request body: " + err.Error())
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.UserID <= 0 {
+			utils.Error("Invalid user ID provided in internal leave request")
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		utils.Info("Decoded internal leave request for user " + strconv.Itoa(req.UserID))
+
+		markOffline(store, req.UserID)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"message":"user left"}`))
+		if err != nil {
+			utils.Error("Failed to write response: " + err.Error())
+			return
+		}
+		utils.Info("Internal user leave request handled successfully")
+	}
+}
@@ -8,18 +8,19 @@ import (
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/broadcaster"
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/memory"
 	"github.com/genryusaishigikuni/messenger/presence-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
 )
 
 type joinRequest struct {
 	ChannelID int `json:"channel_id"`
 }
 
-func JoinHandler(store *memory.PresenceStore, authServiceURL string) http.HandlerFunc {
+func JoinHandler(store *memory.PresenceStore, verifier *tokenverify.Verifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Handling user join request...")
 
 		// Extract user ID from token
-		userID, err := extractUserIDFromToken(r, authServiceURL)
+		userID, err := extractUserIDFromToken(r, verifier)
 		if err != nil {
 			utils.Error("Unauthorized access: " + err.Error())
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -36,8 +37,8 @@ func JoinHandler(store *memory.PresenceStore, authServiceURL string) http.Handle
 		}
 		utils.Info("Decoded join request body successfully")
 
-		// Validate channel ID
-		if req.ChannelID < 1 {
+		// Validate channel ID; 0 means online without a particular channel
+		if req.ChannelID < 0 {
 			utils.Error("Invalid channel ID provided in join request")
 			http.Error(w, "invalid channel_id", http.StatusBadRequest)
 			return
@@ -5,6 +5,7 @@ import (
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/broadcaster"
 	"github.com/genryusaishigikuni/messenger/presence-service/internal/memory"
 	"github.com/genryusaishigikuni/messenger/presence-service/pkg/utils"
+	"github.com/genryusaishigikuni/messenger/tokenverify"
 	"net/http"
 	"strconv"
 )
@@ -13,12 +14,12 @@ type leaveRequest struct {
 	// Placeholder struct for potential future use.
 }
 
-func LeaveHandler(store *memory.PresenceStore, authServiceURL string) http.HandlerFunc {
+func LeaveHandler(store *memory.PresenceStore, verifier *tokenverify.Verifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.Info("Handling user leave request...")
 
 		// Extract user ID from token
-		userID, err := extractUserIDFromToken(r, authServiceURL)
+		userID, err := extractUserIDFromToken(r, verifier)
 		if err != nil {
 			utils.Error("Unauthorized access: " + err.Error())
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -35,26 +36,7 @@ func LeaveHandler(store *memory.PresenceStore, authServiceURL string) http.Handl
 			utils.Info("Decoded leave request body successfully (placeholder)")
 		}
 
-		// Get current presence information
-		utils.Info("Retrieving presence information for user " + strconv.Itoa(userID))
-		presence := store.GetPresence(userID)
-
-		// Remove user from presence store
-		utils.Info("Setting user offline in presence store: User " + strconv.Itoa(userID))
-		store.SetOffline(userID)
-
-		// Determine channel ID for broadcasting
-		var channelID int
-		if presence != nil {
-			channelID = presence.ChannelID
-			utils.Info("User was associated with channel ID: " + strconv.Itoa(channelID))
-		} else {
-			utils.Info("User was not associated with any channel")
-		}
-
-		// Broadcast the user left event
-		utils.Info("Broadcasting user left event to gateway")
-		broadcaster.BroadcastEvent("user_left", userID, channelID)
+		markOffline(store, userID)
 
 		// Respond to the client
 		w.Header().Set("Content-Type", "application/json")
@@ -66,3 +48,28 @@ func LeaveHandler(store *memory.PresenceStore, authServiceURL string) http.Handl
 		utils.Info("User leave request handled successfully")
 	}
 }
+
+// markOffline removes the user from the presence store and tells the gateway
+// which channel they left.
+func markOffline(store *memory.PresenceStore, userID int) {
+	// Get current presence information
+	utils.Info("Retrieving presence information for user " + strconv.Itoa(userID))
+	presence := store.GetPresence(userID)
+
+	// Remove user from presence store
+	utils.Info("Setting user offline in presence store: User " + strconv.Itoa(userID))
+	store.SetOffline(userID)
+
+	// Determine channel ID for broadcasting
+	var channelID int
+	if presence != nil {
+		channelID = presence.ChannelID
+		utils.Info("User was associated with channel ID: " + strconv.Itoa(channelID))
+	} else {
+		utils.Info("User was not associated with any channel")
+	}
+
+	// Broadcast the user left event
+	utils.Info("Broadcasting user left event to gateway")
+	broadcaster.BroadcastEvent("user_left", userID, channelID)
+}
@@ -5,6 +5,9 @@ import "os"
 type Config struct {
 	ServerPort     string
 	AuthServiceURL string
+	// InternalAPIToken authenticates the gateway on the internal presence
+	// endpoints. Those endpoints refuse every call while it is unset.
+	InternalAPIToken string
 }
 
 func LoadConfig() Config {
@@ -18,8 +21,14 @@ func LoadConfig() Config {
 		authURL = "http://localhost:8082"
 	}
 
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		Error("INTERNAL_API_TOKEN not set, internal endpoints will reject all requests")
+	}
+
 	return Config{
-		ServerPort:     port,
-		AuthServiceURL: authURL,
+		ServerPort:       port,
+		AuthServiceURL:   authURL,
+		InternalAPIToken: internalToken,
 	}
 }
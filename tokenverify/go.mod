@@ -0,0 +1,3 @@
+module github.com/genryusaishigikuni/messenger/tokenverify
+
+go 1.23.4
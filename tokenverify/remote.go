@@ -0,0 +1,56 @@
+package tokenverify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type validateResponse struct {
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"session_id"`
+	Valid     bool   `json:"valid"`
+}
+
+// validateRemotely asks the auth service to validate the token.
+func (v *Verifier) validateRemotely(token string) (*Claims, error) {
+	req, err := http.NewRequest("GET", v.authURL+"/api/auth/validate", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call auth service: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logError("Failed to close validate response body: " + err.Error())
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: rejected by auth service", ErrInvalidToken)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token validation failed with status %d", resp.StatusCode)
+	}
+
+	var validateResp validateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&validateResp); err != nil {
+		return nil, fmt.Errorf("failed to parse validate response: %w", err)
+	}
+	if !validateResp.Valid {
+		return nil, fmt.Errorf("%w: rejected by auth service", ErrInvalidToken)
+	}
+
+	return &Claims{
+		UserID:    validateResp.UserID,
+		Username:  validateResp.Username,
+		SessionID: validateResp.SessionID,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package tokenverify
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Revocation is sent by the auth service when it signs a session out.
+type Revocation struct {
+	SessionID string `json:"session_id"`
+	// ExpiresAt is when the last access token issued for the session
+	// expires. The revocation is forgotten after that.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Revoke makes Verify reject every token of the session from now on.
+func (v *Verifier) Revoke(r Revocation) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	for sid, expiresAt := range v.revoked {
+		if !now.Before(expiresAt) {
+			delete(v.revoked, sid)
+		}
+	}
+	if now.Before(r.ExpiresAt) {
+		v.revoked[r.SessionID] = r.ExpiresAt
+	}
+}
+
+// isRevoked reports whether the session was signed out.
+func (v *Verifier) isRevoked(sessionID string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	_, ok := v.revoked[sessionID]
+	return ok
+}
+
+// RevocationHandler receives the revocations the auth service pushes and
+// hands them to Revoke, then to onRevoke if it is not nil. It must be
+// mounted behind internalauth.Require.
+func (v *Verifier) RevocationHandler(onRevoke func(sessionID string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rev Revocation
+		if err := json.NewDecoder(r.Body).Decode(&rev); err != nil {
+			logError("Failed to decode session revocation: " + err.Error())
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if rev.SessionID == "" || rev.ExpiresAt.IsZero() {
+			logError("Session revocation is missing the session ID or expiry")
+			http.Error(w, "session_id and expires_at are required", http.StatusBadRequest)
+			return
+		}
+
+		v.Revoke(rev)
+		if onRevoke != nil {
+			onRevoke(rev.SessionID)
+		}
+		logInfo("Session revoked: " + rev.SessionID)
+
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(`{"message":"received"}`)); err != nil {
+			logError("Failed to send response: " + err.Error())
+		}
+	}
+}
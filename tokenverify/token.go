@@ -0,0 +1,69 @@
+package tokenverify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type tokenClaims struct {
+	UserID    int          `json:"user_id"`
+	Username  string       `json:"username"`
+	SessionID string       `json:"sid"`
+	ExpiresAt *numericDate `json:"exp"`
+	NotBefore *numericDate `json:"nbf"`
+}
+
+// numericDate is a JWT timestamp in seconds, possibly fractional.
+type numericDate float64
+
+func (d numericDate) Time() time.Time {
+	sec, frac := math.Modf(float64(d))
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+type parsedToken struct {
+	header       tokenHeader
+	claims       tokenClaims
+	signingInput []byte
+	signature    []byte
+}
+
+// parseToken splits a compact JWS and decodes its parts. The signature is
+// not checked.
+func parseToken(token string) (*parsedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a compact JWS")
+	}
+
+	parsed := &parsedToken{signingInput: []byte(parts[0] + "." + parts[1])}
+	if err := decodeSegment(parts[0], &parsed.header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	if err := decodeSegment(parts[1], &parsed.claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	parsed.signature = signature
+	return parsed, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
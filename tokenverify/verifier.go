@@ -0,0 +1,236 @@
+// Package tokenverify verifies access tokens issued by the auth service
+// without a round trip per request. Tokens are checked against the public
+// keys the auth service publishes at /.well-known/jwks.json. Tokens naming a
+// key the auth service does not publish are rejected; only when the keys
+// cannot be fetched at all is the token sent to /api/auth/validate instead.
+//
+// The auth service pushes every session it signs out to the services, which
+// pass it to Revoke, usually through RevocationHandler; tokens of that
+// session are rejected from then on. A service that missed the push, because
+// it was down or has restarted since, accepts them until they expire, which
+// is why access tokens are short-lived.
+package tokenverify
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCacheTTL is how long fetched keys are trusted when the auth
+	// service does not say otherwise.
+	defaultCacheTTL = 5 * time.Minute
+	// minRefreshInterval limits how often tokens with unknown keys, or an
+	// unreachable auth service, can trigger a refetch.
+	minRefreshInterval = 30 * time.Second
+	requestTimeout     = 5 * time.Second
+
+	// kidTimeLayout formats the creation time the auth service starts its
+	// generated key IDs with.
+	kidTimeLayout = "20060102T150405Z"
+	// maxClockSkew is how far in the future a key may claim to be created.
+	maxClockSkew = time.Minute
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, badly signed,
+// expired or rejected by the auth service.
+var ErrInvalidToken = errors.New("invalid token")
+
+// errUnknownKey is returned by key when the auth service's current key set
+// does not contain the token's key.
+var errUnknownKey = errors.New("unknown signing key")
+
+// Claims identify the user an access token was issued to.
+type Claims struct {
+	UserID    int
+	Username  string
+	SessionID string
+	// ExpiresAt is zero when the token was validated remotely.
+	ExpiresAt time.Time
+}
+
+// Verifier checks tokens against the auth service at authURL. It is safe
+// for concurrent use.
+type Verifier struct {
+	authURL string
+	client  *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]publicKey // by kid
+	expiresAt   time.Time            // when keys should be refetched
+	lastAttempt time.Time            // last fetch, successful or not
+	lastErr     error                // outcome of the last fetch
+	bypassed    bool                 // the last fetch ignored minRefreshInterval
+	revoked     map[string]time.Time // session ID -> when its tokens expire
+}
+
+// New returns a Verifier for the auth service at authURL, e.g.
+// "http://auth-service:8082". Keys are fetched on first use.
+func New(authURL string) *Verifier {
+	return &Verifier{
+		authURL: authURL,
+		client:  &http.Client{Timeout: requestTimeout},
+		keys:    map[string]publicKey{},
+		revoked: map[string]time.Time{},
+	}
+}
+
+// Verify returns the claims of a valid token.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parsed, err := parseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.key(parsed.header.KeyID)
+	if errors.Is(err, errUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	} else if err != nil {
+		logError("Verifying token remotely: " + err.Error())
+		return v.validateRemotely(token)
+	}
+
+	if parsed.header.Algorithm != key.algorithm {
+		return nil, fmt.Errorf("%w: unexpected signing algorithm %q", ErrInvalidToken, parsed.header.Algorithm)
+	}
+	if !key.verify(parsed.signingInput, parsed.signature) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+	}
+
+	now := time.Now()
+	if parsed.claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidToken)
+	}
+	expiresAt := parsed.claims.ExpiresAt.Time()
+	if !now.Before(expiresAt) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if parsed.claims.NotBefore != nil && now.Before(parsed.claims.NotBefore.Time()) {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+	if parsed.claims.SessionID == "" {
+		return nil, fmt.Errorf("%w: token has no session", ErrInvalidToken)
+	}
+	if v.isRevoked(parsed.claims.SessionID) {
+		return nil, fmt.Errorf("%w: session revoked", ErrInvalidToken)
+	}
+
+	return &Claims{
+		UserID:    parsed.claims.UserID,
+		Username:  parsed.claims.Username,
+		SessionID: parsed.claims.SessionID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// key returns the published key with the given ID, refetching the key set
+// when it is stale or does not contain the key. Refetches happen at most
+// once per minRefreshInterval, so tokens with made-up key IDs cannot flood
+// the auth service. The one exception is a key created after every cached
+// key, which is what the first token after a rotation carries: it may
+// trigger one extra refetch per interval. errUnknownKey means the key set is
+// available and lacks the key; any other error means the key set could not
+// be fetched.
+func (v *Verifier) key(kid string) (publicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	fresh := time.Now().Before(v.expiresAt)
+	v.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// Another caller may have refetched while we waited for the lock
+	if key, ok := v.keys[kid]; ok && time.Now().Before(v.expiresAt) {
+		return key, nil
+	}
+
+	throttled := time.Since(v.lastAttempt) < minRefreshInterval
+	if !throttled || (!v.bypassed && v.newerThanCached(kid)) {
+		v.bypassed = throttled
+		v.lastErr = v.refresh()
+	}
+	// Stale keys still beat a round trip while the auth service is down
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if v.lastErr != nil {
+		return publicKey{}, v.lastErr
+	}
+	return publicKey{}, fmt.Errorf("%w %q", errUnknownKey, kid)
+}
+
+// newerThanCached reports whether the key ID carries a creation time later
+// than that of every cached key, and not in the future. Must be called with
+// v.mu held.
+func (v *Verifier) newerThanCached(kid string) bool {
+	created, ok := kidCreatedAt(kid)
+	if !ok || created.After(time.Now().Add(maxClockSkew)) {
+		return false
+	}
+	for cachedKid := range v.keys {
+		if cachedAt, ok := kidCreatedAt(cachedKid); ok && !created.After(cachedAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// kidCreatedAt reads the creation time from the start of a key ID generated
+// by the auth service. Other key IDs have none.
+func kidCreatedAt(kid string) (time.Time, bool) {
+	if len(kid) <= len(kidTimeLayout) || kid[len(kidTimeLayout)] != '-' {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(kidTimeLayout, kid[:len(kidTimeLayout)])
+	return created, err == nil
+}
+
+// refresh replaces the cached keys. Must be called with v.mu held.
+func (v *Verifier) refresh() error {
+	v.lastAttempt = time.Now()
+	keys, ttl, err := fetchKeys(v.client, v.authURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	v.keys = keys
+	v.expiresAt = time.Now().Add(ttl)
+	logInfo(fmt.Sprintf("Fetched %d signing keys from the auth service", len(keys)))
+	return nil
+}
+
+// publicKey is a verification key from the auth service's key set.
+type publicKey struct {
+	algorithm string
+	ed25519   ed25519.PublicKey
+	rsa       *rsa.PublicKey
+}
+
+func (k publicKey) verify(signingInput, signature []byte) bool {
+	switch k.algorithm {
+	case "EdDSA":
+		return ed25519.Verify(k.ed25519, signingInput, signature)
+	case "RS256":
+		digest := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(k.rsa, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+func logInfo(msg string) {
+	log.Println("[INFO]", msg)
+}
+
+func logError(msg string) {
+	log.Println("[ERROR]", msg)
+}
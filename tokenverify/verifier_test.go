@@ -0,0 +1,246 @@
+package tokenverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// authServer stands in for the auth service: it publishes Ed25519 keys and
+// accepts every token on /api/auth/validate, counting the calls.
+type authServer struct {
+	*httptest.Server
+	jwksDown     bool
+	jwksFetches  atomic.Int32
+	remoteChecks atomic.Int32
+
+	mu        sync.Mutex
+	private   ed25519.PrivateKey            // the newest key
+	privates  map[string]ed25519.PrivateKey // by kid
+	published []jwk
+}
+
+func newAuthServer(t *testing.T, jwksDown bool) *authServer {
+	t.Helper()
+	s := &authServer{jwksDown: jwksDown, privates: map[string]ed25519.PrivateKey{}}
+	s.rotate(t, "k1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		s.jwksFetches.Add(1)
+		if s.jwksDown {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.published})
+	})
+	mux.HandleFunc("/api/auth/validate", func(w http.ResponseWriter, r *http.Request) {
+		s.remoteChecks.Add(1)
+		_ = json.NewEncoder(w).Encode(validateResponse{UserID: 7, Username: "remote", Valid: true})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// rotate publishes a new key with the given ID and signs with it from now
+// on. Earlier keys stay published.
+func (s *authServer) rotate(t *testing.T, kid string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.private = private
+	s.privates[kid] = private
+	s.published = append(s.published, jwk{
+		KeyType:   "OKP",
+		KeyID:     kid,
+		Algorithm: "EdDSA",
+		Use:       "sig",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(public),
+	})
+}
+
+// sign returns a token for user 42 in session sid, signed with the key kid
+// names, or the newest key when kid is not published, whatever alg the
+// header claims.
+func (s *authServer) sign(t *testing.T, alg, kid, sid string, expiresAt time.Time) string {
+	t.Helper()
+	header, err := json.Marshal(tokenHeader{Algorithm: alg, KeyID: kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"user_id":  42,
+		"username": "alice",
+		"sid":      sid,
+		"exp":      expiresAt.Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	s.mu.Lock()
+	private, ok := s.privates[kid]
+	if !ok {
+		private = s.private
+	}
+	s.mu.Unlock()
+	signature := ed25519.Sign(private, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		alg      string
+		kid      string
+		sid      string
+		expires  time.Duration
+		jwksDown bool
+
+		wantUserID int // 0 when the token must be rejected
+		// Each token is verified twice; these count requests to the auth
+		// service across both calls.
+		wantFetches      int32
+		wantRemoteChecks int32
+	}{
+		{name: "valid", alg: "EdDSA", kid: "k1", sid: "session", expires: time.Minute,
+			wantUserID: 42, wantFetches: 1},
+		{name: "expired", alg: "EdDSA", kid: "k1", sid: "session", expires: -time.Minute,
+			wantFetches: 1},
+		{name: "wrong alg", alg: "RS256", kid: "k1", sid: "session", expires: time.Minute,
+			wantFetches: 1},
+		{name: "unknown kid", alg: "EdDSA", kid: "forged", sid: "session", expires: time.Minute,
+			wantFetches: 1},
+		{name: "no session", alg: "EdDSA", kid: "k1", expires: time.Minute,
+			wantFetches: 1},
+		{name: "jwks unreachable", alg: "EdDSA", kid: "k1", sid: "session", expires: time.Minute, jwksDown: true,
+			wantUserID: 7, wantFetches: 1, wantRemoteChecks: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthServer(t, tt.jwksDown)
+			verifier := New(server.URL)
+			token := server.sign(t, tt.alg, tt.kid, tt.sid, time.Now().Add(tt.expires))
+
+			for i := 0; i < 2; i++ {
+				claims, err := verifier.Verify(token)
+				if tt.wantUserID == 0 {
+					if !errors.Is(err, ErrInvalidToken) {
+						t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				if claims.UserID != tt.wantUserID {
+					t.Errorf("Verify() user = %d, want %d", claims.UserID, tt.wantUserID)
+				}
+			}
+
+			if got := server.jwksFetches.Load(); got != tt.wantFetches {
+				t.Errorf("JWKS fetched %d times, want %d", got, tt.wantFetches)
+			}
+			if got := server.remoteChecks.Load(); got != tt.wantRemoteChecks {
+				t.Errorf("validate called %d times, want %d", got, tt.wantRemoteChecks)
+			}
+		})
+	}
+}
+
+// TestRotation checks that a verifier which fetched the key set moments
+// before a rotation accepts tokens signed with the new key right away.
+func TestRotation(t *testing.T) {
+	server := newAuthServer(t, false)
+	verifier := New(server.URL)
+	now := time.Now().UTC()
+	next := now.Format(kidTimeLayout) + "-0002"
+
+	steps := []struct {
+		name        string
+		rotate      bool // publish next and sign with it before this step
+		kid         string
+		wantValid   bool
+		wantFetches int32
+	}{
+		{name: "first key", kid: "k1", wantValid: true, wantFetches: 1},
+		{name: "key dated in the future", kid: now.Add(time.Hour).Format(kidTimeLayout) + "-0003",
+			wantFetches: 1},
+		{name: "rotated key", rotate: true, kid: next, wantValid: true, wantFetches: 2},
+		{name: "made-up key newer than the rotated one", kid: now.Add(2*time.Second).Format(kidTimeLayout) + "-0004",
+			wantFetches: 2},
+		{name: "retired key", kid: "k1", wantValid: true, wantFetches: 2},
+	}
+	for _, step := range steps {
+		if step.rotate {
+			server.rotate(t, next)
+		}
+		_, err := verifier.Verify(server.sign(t, "EdDSA", step.kid, "session", time.Now().Add(time.Minute)))
+		if step.wantValid && err != nil {
+			t.Errorf("%s: Verify() error = %v", step.name, err)
+		} else if !step.wantValid && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify() error = %v, want ErrInvalidToken", step.name, err)
+		}
+		if got := server.jwksFetches.Load(); got != step.wantFetches {
+			t.Errorf("%s: JWKS fetched %d times, want %d", step.name, got, step.wantFetches)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	server := newAuthServer(t, false)
+	verifier := New(server.URL)
+	revoked := server.sign(t, "EdDSA", "k1", "revoked", time.Now().Add(time.Minute))
+	other := server.sign(t, "EdDSA", "k1", "other", time.Now().Add(time.Minute))
+	lapsed := server.sign(t, "EdDSA", "k1", "lapsed", time.Now().Add(time.Minute))
+
+	handler := verifier.RevocationHandler(func(sessionID string) {
+		if sessionID != "revoked" {
+			t.Errorf("onRevoke(%q), want revoked", sessionID)
+		}
+	})
+	body, err := json.Marshal(Revocation{SessionID: "revoked", ExpiresAt: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/api/internal/sessions/revoked", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RevocationHandler status = %d, want 200", rec.Code)
+	}
+	// A revocation arriving after the session's tokens expired is ignored
+	verifier.Revoke(Revocation{SessionID: "lapsed", ExpiresAt: time.Now().Add(-time.Second)})
+
+	if _, err := verifier.Verify(revoked); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify(revoked session) error = %v, want ErrInvalidToken", err)
+	}
+	for _, token := range []string{other, lapsed} {
+		if _, err := verifier.Verify(token); err != nil {
+			t.Errorf("Verify() error = %v, want a valid token", err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/api/internal/sessions/revoked", strings.NewReader(`{"session_id":"x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("RevocationHandler without expires_at status = %d, want 400", rec.Code)
+	}
+}